@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/m1al04949/weatherbot/internal/api"
+)
+
+// Write OpenAPI spec of the HTTP API, stdout if output is not set
+func main() {
+	output := flag.String("o", "", "output file")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(api.Spec(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 
-	"github.com/m1al04949/weatherbot/internal/app"
+	"github.com/m1al04949/weatherbot/internal/cli"
 )
 
 func main() {
-	if err := app.RunBot(); err != nil {
+	if err := cli.Run(os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			os.Exit(2)
+		}
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const (
+	keyHeader       = "X-API-Key"
+	shutdownTimeout = 5 * time.Second
+)
+
+type clientKey struct{}
+
+// JSON API over the bot's weather data
+type Server struct {
+	cfg     *config.Config
+	log     *slog.Logger
+	weather *weatherservice.WeatherService
+	guard   *ratelimit.Guard
+	mux     *http.ServeMux
+}
+
+func New(
+	cfg *config.Config, log *slog.Logger,
+	weather *weatherservice.WeatherService, guard *ratelimit.Guard,
+) *Server {
+	s := &Server{
+		cfg:     cfg,
+		log:     log,
+		weather: weather,
+		guard:   guard,
+		mux:     http.NewServeMux(),
+	}
+
+	for _, e := range endpoints() {
+		handler := e.handler(s)
+		if e.auth {
+			handler = s.authorized(handler)
+		}
+		s.mux.Handle(e.method+" "+e.path, handler)
+	}
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen on Port until context is done
+func (s *Server) Run(ctx context.Context) error {
+	op := "api.run"
+
+	srv := &http.Server{
+		Addr:              ":" + s.cfg.Port,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			s.log.Error(err.Error())
+		}
+	}()
+
+	s.log.Info("api is started", slog.String("addr", srv.Addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GET /v1/weather
+func (s *Server) handleWeather(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.parseQuery(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := s.weather.Current(r.Context(), query)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	// Weather is the same until cache is refreshed
+	ttl := time.Duration(s.cfg.Cache.TTL) * time.Minute
+	maxAge := max(0, ttl-time.Since(current.UpdatedAt))
+	etag := fmt.Sprintf(`"%s"`, strconv.FormatInt(current.UpdatedAt.UnixNano(), 36))
+
+	w.Header().Set("Last-Modified", current.UpdatedAt.UTC().Format(http.TimeFormat))
+	s.respond(w, r, etag, maxAge, CurrentResponse{
+		Location:  newLocation(current.Location),
+		UpdatedAt: current.UpdatedAt,
+		Weather:   newWeather(current.Weather),
+	})
+}
+
+// GET /v1/forecast
+func (s *Server) handleForecast(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.parseQuery(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.weather.Forecast(r.Context(), query)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	resp := ForecastResponse{
+		Location: newLocation(result.Location),
+		Items:    make([]Weather, 0, len(result.Items)),
+		Days:     []Day{},
+	}
+	for _, item := range result.Items {
+		resp.Items = append(resp.Items, newWeather(item))
+	}
+	for _, day := range forecast.Daily(result.Items) {
+		resp.Days = append(resp.Days, newDay(day))
+	}
+
+	// Tag is the content hash, forecast from cache keeps it
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+
+	s.respond(w, r, etag, time.Duration(s.cfg.API.ForecastMaxAge)*time.Second, resp)
+}
+
+// GET /v1/openapi.json
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Spec()); err != nil {
+		s.log.Error(err.Error())
+	}
+}
+
+// City or coordinates from query string, client gets 400 if both or none are set
+func (s *Server) parseQuery(w http.ResponseWriter, r *http.Request) (weatherservice.Query, bool) {
+	values := r.URL.Query()
+
+	city := strings.TrimSpace(values.Get("city"))
+	lat, lon := values.Get("lat"), values.Get("lon")
+
+	switch {
+	case city != "" && lat == "" && lon == "":
+		return weatherservice.Query{City: city, Allow: s.allow(r)}, true
+	case city == "" && lat != "" && lon != "":
+		latValue, latErr := strconv.ParseFloat(lat, 64)
+		lonValue, lonErr := strconv.ParseFloat(lon, 64)
+		if latErr != nil || lonErr != nil || latValue < -90 || latValue > 90 || lonValue < -180 || lonValue > 180 {
+			s.writeError(w, http.StatusBadRequest, "invalid coordinates")
+			return weatherservice.Query{}, false
+		}
+		return weatherservice.Query{
+			Location: &models.CordinatesResponse{Lat: latValue, Lon: lonValue},
+			Allow:    s.allow(r),
+		}, true
+	}
+
+	s.writeError(w, http.StatusBadRequest, "either city or lat and lon are required")
+	return weatherservice.Query{}, false
+}
+
+// Requests are accepted with one of configured keys
+func (s *Server) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(keyHeader)
+		if key == "" {
+			key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+
+		for i, k := range s.cfg.API.Keys {
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				// Clients share provider limits with bot users under negative IDs
+				ctx := context.WithValue(r.Context(), clientKey{}, -int64(i+1))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.writeError(w, http.StatusUnauthorized, "invalid api key")
+	})
+}
+
+func (s *Server) allow(r *http.Request) weatherservice.AllowFunc {
+	clientID, _ := r.Context().Value(clientKey{}).(int64)
+	return func(calls int) error {
+		return s.guard.Allow(clientID, calls)
+	}
+}
+
+// Write JSON with cache headers, 304 if client has the same version
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, etag string, maxAge time.Duration, body any) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+
+	if notModified(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, weatherservice.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "location not found")
+	case errors.Is(err, weatherservice.ErrRateLimited):
+		s.log.Info("api provider call denied", slog.String("reason", err.Error()))
+		w.Header().Set("Retry-After", "60")
+		s.writeError(w, http.StatusTooManyRequests, "too many requests")
+	case errors.Is(err, weatherservice.ErrUnavailable):
+		s.log.Error(err.Error())
+		s.writeError(w, http.StatusBadGateway, "weather provider is unavailable")
+	default:
+		s.log.Error(err.Error())
+		s.writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.writeJSON(w, status, Error{Error: message})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.log.Error(err.Error())
+	}
+}
+
+func notModified(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+const key = "secret"
+
+var msk = time.FixedZone("MSK", 3*60*60)
+
+// Provider knowing Kazan only
+type fakeProvider struct {
+	down  bool
+	calls int
+}
+
+func (p *fakeProvider) Coordinates(city string) (*models.Cordinates, error) {
+	p.calls++
+	if city != "Казань" {
+		return nil, fmt.Errorf("fake: %w", openweather.ErrNotFound)
+	}
+	return &models.Cordinates{Lat: 55.79, Lon: 49.12}, nil
+}
+
+func (p *fakeProvider) Locations(city string, limit int) ([]models.CordinatesResponse, error) {
+	return nil, errors.New("fake: not implemented")
+}
+
+func (p *fakeProvider) CurrentWeather(lat, lon float64) (*models.Weather, error) {
+	p.calls++
+	if p.down {
+		return nil, errors.New("fake: connection refused")
+	}
+	return &models.Weather{Date: time.Date(2025, 10, 20, 12, 0, 0, 0, msk), Temp: 7.4, Code: 500, Description: "небольшой дождь"}, nil
+}
+
+func (p *fakeProvider) ForecastWeather(lat, lon float64) (*[]models.Weather, error) {
+	p.calls++
+	if p.down {
+		return nil, errors.New("fake: connection refused")
+	}
+	items := []models.Weather{
+		{Date: time.Date(2025, 10, 20, 15, 0, 0, 0, msk), Temp: 8, Code: 500, Description: "небольшой дождь"},
+		{Date: time.Date(2025, 10, 20, 18, 0, 0, 0, msk), Temp: 5, Code: 800, Description: "ясно"},
+	}
+	return &items, nil
+}
+
+type fakeCache struct {
+	weather   map[string]models.CacheWeather
+	forecasts map[string]models.CacheForecast
+}
+
+func (c *fakeCache) GetWeather(ctx context.Context, city string) (*models.CacheWeather, error) {
+	weather, ok := c.weather[city]
+	if !ok {
+		return nil, errors.New("redis: nil")
+	}
+	return &weather, nil
+}
+
+func (c *fakeCache) UpdateWeather(ctx context.Context, weather models.CacheWeather) error {
+	c.weather[weather.City] = weather
+	return nil
+}
+
+func (c *fakeCache) GetForecast(ctx context.Context, city string) (*models.CacheForecast, error) {
+	forecast, ok := c.forecasts[city]
+	if !ok {
+		return nil, errors.New("redis: nil")
+	}
+	return &forecast, nil
+}
+
+func (c *fakeCache) UpdateForecast(ctx context.Context, forecast models.CacheForecast) error {
+	c.forecasts[forecast.City] = forecast
+	return nil
+}
+
+type noRecorder struct{}
+
+func (noRecorder) Record(ctx context.Context, weather models.CacheWeather) {}
+
+func (noRecorder) RecordForecast(
+	ctx context.Context, provider string, location models.CordinatesResponse, issued time.Time, items []models.Weather,
+) {
+}
+
+func (noRecorder) ChatSettings(ctx context.Context, chatID int64) (*storage.ChatSettings, error) {
+	settings := storage.DefaultChatSettings(chatID)
+	return &settings, nil
+}
+
+type fixture struct {
+	server   *Server
+	provider *fakeProvider
+	cache    *fakeCache
+}
+
+// Server with weather service over fakes, budget of provider calls is zero for unlimited
+func newFixture(budget int) *fixture {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := &fixture{provider: &fakeProvider{}, cache: &fakeCache{
+		weather:   map[string]models.CacheWeather{},
+		forecasts: map[string]models.CacheForecast{},
+	}}
+
+	cfg := &config.Config{
+		Cache: config.Cache{TTL: 10},
+		API:   config.API{Keys: []string{"other", key}, ForecastMaxAge: 600},
+	}
+	weather := weatherservice.New(log, f.provider, f.cache, noRecorder{}, noRecorder{}, noRecorder{})
+	guard := ratelimit.NewGuard(
+		ratelimit.New(100, 100), ratelimit.New(100, 100), ratelimit.NewBudget(budget), ratelimit.NewBanList())
+	f.server = New(cfg, log, weather, guard)
+
+	return f
+}
+
+// Header of name and value pairs
+func headers(pairs ...string) http.Header {
+	header := http.Header{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		header.Set(pairs[i], pairs[i+1])
+	}
+
+	return header
+}
+
+func (f *fixture) get(target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if header == nil {
+		header = headers(keyHeader, key)
+	}
+	req.Header = header
+
+	w := httptest.NewRecorder()
+	f.server.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{name: "key header", path: "/v1/weather?city=Казань", header: headers(keyHeader, key), want: http.StatusOK},
+		{name: "bearer", path: "/v1/weather?city=Казань", header: headers("Authorization", "Bearer "+key), want: http.StatusOK},
+		{name: "wrong key", path: "/v1/weather?city=Казань", header: headers(keyHeader, "secre"), want: http.StatusUnauthorized},
+		{name: "no key", path: "/v1/forecast?city=Казань", header: headers(), want: http.StatusUnauthorized},
+		{name: "metrics", path: "/v1/metrics", header: headers(keyHeader, key), want: http.StatusOK},
+		{name: "metrics without key", path: "/v1/metrics", header: headers(), want: http.StatusUnauthorized},
+		{name: "public spec", path: "/v1/openapi.json", header: headers(), want: http.StatusOK},
+		{name: "unknown path", path: "/v1/unknown", header: headers(keyHeader, key), want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newFixture(0).get(tt.path, tt.header)
+			if w.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("challenge is not set")
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		budget  int
+		down    bool
+		want    int
+		message string
+	}{
+		{name: "no location", path: "/v1/weather", want: http.StatusBadRequest, message: "either city or lat and lon are required"},
+		{name: "city and coordinates", path: "/v1/weather?city=Казань&lat=1&lon=2", want: http.StatusBadRequest},
+		{name: "latitude only", path: "/v1/forecast?lat=1", want: http.StatusBadRequest},
+		{name: "invalid coordinates", path: "/v1/weather?lat=91&lon=0", want: http.StatusBadRequest, message: "invalid coordinates"},
+		{name: "not a number", path: "/v1/weather?lat=north&lon=0", want: http.StatusBadRequest, message: "invalid coordinates"},
+		{name: "unknown city", path: "/v1/weather?city=Нигде", want: http.StatusNotFound, message: "location not found"},
+		{name: "budget spent", path: "/v1/forecast?city=Казань", budget: 1, want: http.StatusTooManyRequests},
+		{name: "provider is down", path: "/v1/weather?lat=55.79&lon=49.12", down: true, want: http.StatusBadGateway},
+		{name: "forecast provider is down", path: "/v1/forecast?city=Казань", down: true, want: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(tt.budget)
+			f.provider.down = tt.down
+
+			w := f.get(tt.path, nil)
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			var body Error
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error == "" {
+				t.Fatalf("error body: %v", err)
+			}
+			if tt.message != "" && body.Error != tt.message {
+				t.Errorf("message: got %q, want %q", body.Error, tt.message)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After is not set")
+			}
+		})
+	}
+}
+
+func TestWeatherCaching(t *testing.T) {
+	f := newFixture(0)
+	updated := time.Now().Add(-4 * time.Minute)
+	f.cache.weather["Казань"] = models.CacheWeather{
+		City: "Казань", Lat: 55.79, Lon: 49.12, UpdatedAt: updated,
+		Weather: models.Weather{Date: updated.In(msk), Temp: 7.4, Code: 500, Description: "небольшой дождь"},
+	}
+
+	w := f.get("/v1/weather?city=Казань", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+
+	var body CurrentResponse
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Location.Name != "Казань" || body.Weather.Temp != 7.4 || !body.UpdatedAt.Equal(updated) {
+		t.Errorf("got %+v", body)
+	}
+	if f.provider.calls != 0 {
+		t.Errorf("cached weather must not call provider: %d calls", f.provider.calls)
+	}
+
+	// Fresh until the cache is refreshed
+	var maxAge int
+	if _, err := fmt.Sscanf(w.Header().Get("Cache-Control"), "private, max-age=%d", &maxAge); err != nil {
+		t.Fatalf("cache control: %q", w.Header().Get("Cache-Control"))
+	}
+	if maxAge < 359 || maxAge > 360 {
+		t.Errorf("max-age: got %d, want 6 minutes left of TTL", maxAge)
+	}
+	if w.Header().Get("Last-Modified") != updated.UTC().Format(http.TimeFormat) {
+		t.Errorf("last modified: got %q", w.Header().Get("Last-Modified"))
+	}
+
+	etag := w.Header().Get("ETag")
+	for _, match := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		w := f.get("/v1/weather?city=Казань", headers(keyHeader, key, "If-None-Match", match))
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: got %d with %d bytes, want 304", match, w.Code, w.Body.Len())
+		}
+	}
+
+	if w := f.get("/v1/weather?city=Казань", headers(keyHeader, key, "If-None-Match", `"other"`)); w.Code != http.StatusOK {
+		t.Errorf("other version: got %d, want 200", w.Code)
+	}
+
+	// Stale cache entry isn't cached by clients
+	f.cache.weather["Казань"] = models.CacheWeather{City: "Казань", UpdatedAt: time.Now().Add(-time.Hour)}
+	if w := f.get("/v1/weather?city=Казань", nil); w.Header().Get("Cache-Control") != "private, max-age=0" {
+		t.Errorf("stale cache control: %q", w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestForecast(t *testing.T) {
+	f := newFixture(0)
+
+	w := f.get("/v1/forecast?city=Казань", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "private, max-age=600" {
+		t.Errorf("cache control: got %q", cc)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("content type: got %q", w.Header().Get("Content-Type"))
+	}
+
+	var body ForecastResponse
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Location.Name != "Казань" || len(body.Items) != 2 || len(body.Days) != 1 || body.Days[0].Date != "2025-10-20" {
+		t.Errorf("got %+v", body)
+	}
+
+	// Tag is the content hash, the same forecast isn't sent again
+	etag := w.Header().Get("ETag")
+	if w := f.get("/v1/forecast?city=Казань", headers(keyHeader, key, "If-None-Match", etag)); w.Code != http.StatusNotModified {
+		t.Errorf("got %d, want 304", w.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics.ForecastTempMAE.Set("openweather:24h", new(expvar.Float))
+
+	w := newFixture(0).get("/v1/metrics", headers(keyHeader, key))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"forecast_temp_mae", "forecast_samples", "updates", "cache_hits"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("%s is not published", name)
+		}
+	}
+	if !strings.Contains(string(vars["forecast_temp_mae"]), "openweather:24h") {
+		t.Errorf("forecast_temp_mae: got %s", vars["forecast_temp_mae"])
+	}
+}
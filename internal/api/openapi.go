@@ -0,0 +1,220 @@
+package api
+
+import (
+	"expvar"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type param struct {
+	name        string
+	typ         string
+	description string
+}
+
+type endpoint struct {
+	method   string
+	path     string
+	summary  string
+	auth     bool
+	params   []param
+	response any
+	handler  func(s *Server) http.Handler
+}
+
+var locationParams = []param{
+	{name: "city", typ: "string", description: "City name, instead of lat and lon"},
+	{name: "lat", typ: "number", description: "Latitude"},
+	{name: "lon", typ: "number", description: "Longitude"},
+}
+
+//go:generate go run ../../cmd/openapi -o ../../api/openapi.json
+
+// Routes of the server, the spec is generated from them
+func endpoints() []endpoint {
+	return []endpoint{
+		{
+			method:   http.MethodGet,
+			path:     "/v1/weather",
+			summary:  "Current weather, cached by the bot",
+			auth:     true,
+			params:   locationParams,
+			response: CurrentResponse{},
+			handler:  func(s *Server) http.Handler { return http.HandlerFunc(s.handleWeather) },
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/v1/forecast",
+			summary:  "Forecast for 5 days in 3-hour slots and daily aggregates",
+			auth:     true,
+			params:   locationParams,
+			response: ForecastResponse{},
+			handler:  func(s *Server) http.Handler { return http.HandlerFunc(s.handleForecast) },
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/v1/metrics",
+			summary: "Counters of the bot in expvar format, e.g. forecast_temp_mae by provider and lead time",
+			auth:    true,
+			handler: func(s *Server) http.Handler { return expvar.Handler() },
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/v1/openapi.json",
+			summary: "This specification",
+			handler: func(s *Server) http.Handler { return http.HandlerFunc(s.handleOpenAPI) },
+		},
+	}
+}
+
+// OpenAPI 3 document of the server
+func Spec() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+
+	errorRef := schemaRef(reflect.TypeOf(Error{}), schemas)
+
+	for _, e := range endpoints() {
+		operation := map[string]any{
+			"summary": e.summary,
+		}
+
+		var params []map[string]any
+		for _, p := range e.params {
+			params = append(params, map[string]any{
+				"name":        p.name,
+				"in":          "query",
+				"description": p.description,
+				"schema":      map[string]any{"type": p.typ},
+			})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		responses := map[string]any{}
+		if e.response != nil {
+			responses["200"] = map[string]any{
+				"description": "OK",
+				"headers": map[string]any{
+					"ETag":          map[string]any{"schema": map[string]any{"type": "string"}},
+					"Cache-Control": map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemaRef(reflect.TypeOf(e.response), schemas)},
+				},
+			}
+			responses["304"] = map[string]any{"description": "Not modified since ETag from If-None-Match"}
+			for status, description := range map[string]string{
+				"400": "Neither city nor coordinates are set",
+				"404": "City is not found",
+				"429": "Limit of weather provider calls is exceeded",
+				"502": "Weather provider is unavailable",
+			} {
+				responses[status] = map[string]any{
+					"description": description,
+					"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+				}
+			}
+		} else {
+			responses["200"] = map[string]any{"description": "OK"}
+		}
+		if e.auth {
+			operation["security"] = []map[string]any{{"apiKey": []string{}}, {"bearer": []string{}}}
+			responses["401"] = map[string]any{
+				"description": "API key is missing or invalid",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+			}
+		}
+		operation["responses"] = responses
+
+		item, _ := paths[e.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[e.path] = item
+		}
+		item[strings.ToLower(e.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Weatherbot API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": keyHeader},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema of Go type, structs are added to schemas and referenced by name
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// Reserve name before fields for recursive types
+			schemas[t.Name()] = nil
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.Float32, t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	}
+
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		schema := schemaRef(field.Type, schemas)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			if _, ok := schema["$ref"]; ok {
+				// Siblings of $ref are ignored in OpenAPI 3.0
+				schema = map[string]any{"allOf": []any{schema}, "description": doc}
+			} else {
+				schema["description"] = doc
+			}
+		}
+		properties[name] = schema
+
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+// Field descriptions in doc tags go to the OpenAPI spec
+
+type Location struct {
+	Name string  `json:"name,omitempty" doc:"Requested city, empty for coordinates"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+type Weather struct {
+	Time          time.Time `json:"time" doc:"Time of observation or forecast slot in the location's timezone"`
+	Temp          float64   `json:"temp" doc:"Temperature, °C"`
+	Humidity      int64     `json:"humidity" doc:"Relative humidity, %"`
+	WindSpeed     float64   `json:"wind_speed" doc:"Wind speed, m/s"`
+	Precipitation float64   `json:"precipitation" doc:"Rain and snow volume, mm"`
+	Code          int       `json:"code" doc:"OpenWeather condition code"`
+	Description   string    `json:"description" doc:"Condition in Russian"`
+}
+
+type Day struct {
+	Date          string  `json:"date" doc:"Local date, YYYY-MM-DD"`
+	MinTemp       float64 `json:"min_temp" doc:"°C"`
+	MaxTemp       float64 `json:"max_temp" doc:"°C"`
+	Code          int     `json:"code" doc:"OpenWeather code of the most frequent condition"`
+	Description   string  `json:"description"`
+	Precipitation float64 `json:"precipitation" doc:"mm per day"`
+	MaxWindSpeed  float64 `json:"max_wind_speed" doc:"m/s"`
+}
+
+type CurrentResponse struct {
+	Location  Location  `json:"location"`
+	UpdatedAt time.Time `json:"updated_at" doc:"Time weather was received from provider"`
+	Weather   Weather   `json:"weather"`
+}
+
+type ForecastResponse struct {
+	Location Location  `json:"location"`
+	Items    []Weather `json:"items" doc:"3-hour slots for 5 days"`
+	Days     []Day     `json:"days" doc:"Daily aggregates of slots"`
+}
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Coordinates of requested location
+func newLocation(location models.CordinatesResponse) Location {
+	return Location{Name: location.Name, Lat: location.Lat, Lon: location.Lon}
+}
+
+func newWeather(weather models.Weather) Weather {
+	return Weather{
+		Time:          weather.Date,
+		Temp:          weather.Temp,
+		Humidity:      weather.Humidity,
+		WindSpeed:     weather.Speed,
+		Precipitation: weather.Precipitation,
+		Code:          weather.Code,
+		Description:   weather.Description,
+	}
+}
+
+func newDay(day models.DailyForecast) Day {
+	return Day{
+		Date:          day.Date.Format(time.DateOnly),
+		MinTemp:       day.MinTemp,
+		MaxTemp:       day.MaxTemp,
+		Code:          day.Code,
+		Description:   day.Description,
+		Precipitation: day.Precipitation,
+		MaxWindSpeed:  day.MaxSpeed,
+	}
+}
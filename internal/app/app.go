@@ -10,12 +10,22 @@ import (
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/api"
 	"github.com/m1al04949/weatherbot/internal/cache/redis"
 	"github.com/m1al04949/weatherbot/internal/clients/huggingface"
+	"github.com/m1al04949/weatherbot/internal/clients/openmeteo"
 	"github.com/m1al04949/weatherbot/internal/clients/openweather"
 	"github.com/m1al04949/weatherbot/internal/config"
 	"github.com/m1al04949/weatherbot/internal/handler"
+	"github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/outbox"
+	"github.com/m1al04949/weatherbot/internal/repositories/accuracyrepository"
 	"github.com/m1al04949/weatherbot/internal/repositories/cacherepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/historyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/observationrepository"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
 )
 
 const (
@@ -32,6 +42,9 @@ func RunBot() error {
 	defer cancel()
 	// Initialize config
 	cfg := config.MustLoad()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 	// Initialize logger
 	log := setupLogger(cfg.Env)
 
@@ -47,23 +60,79 @@ func RunBot() error {
 	log.Info("authorized on account", slog.String("botname", bot.Self.UserName))
 
 	// Initialize OpenWeather client
-	owClient := openweather.New(cfg.OpenWeatherKey)
-	// Initialize Hugging Face client
-	hfClient := huggingface.New(cfg.HuggingFaceKey)
+	owClient := openweather.New(cfg.OpenWeatherKey, openweather.Transport(cfg.OpenWeather))
+	// Initialize Hugging Face client, illustrations are optional
+	var hfClient *huggingface.HuggingFaceClient
+	if cfg.HuggingFace.Key != "" {
+		hfClient = huggingface.New(cfg.HuggingFace)
+	}
 	// Initialize Cache
 	cache := redis.NewCache(
 		cfg.Cache.Address, cfg.Cache.Password, cfg.Cache.DB,
-		time.Duration(cfg.Cache.TTL)*time.Minute, log)
+		time.Duration(cfg.Cache.TTL)*time.Minute, time.Duration(cfg.Cache.ForecastTTL)*time.Minute, log)
+	// Initialize storage
+	storage, err := sqlite.New(cfg.Storage.Path, log)
+	if err != nil {
+		return err
+	}
+	// Initialize limits of weather provider calls
+	guard := ratelimit.NewGuard(
+		ratelimit.New(cfg.RateLimit.ProviderRate, cfg.RateLimit.ProviderBurst),
+		ratelimit.New(cfg.RateLimit.GlobalRate, cfg.RateLimit.GlobalBurst),
+		ratelimit.NewBudget(cfg.RateLimit.DailyBudget),
+		ratelimit.NewBanList(),
+	)
+	// Initialize message templates
+	templates, err := format.New(cfg.Templates)
+	if err != nil {
+		return err
+	}
+	// Initialize outbound queue of bulk messages
+	outbox := outbox.New(cfg.Outbox, log, bot, storage)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outbox.Run(ctx)
+	}()
 	// Initialize repositories
-	cacheRep := cacherepository.New(cfg, log, cache)
+	observationRep := observationrepository.New(cfg.Observations, log, storage)
+	cacheRep := cacherepository.New(cfg, log, cache, observationRep)
+	historyRep := historyrepository.New(log, openmeteo.New(cfg.History), storage)
+	accuracyRep := accuracyrepository.New(log, storage)
+	// Weather for all front-ends
+	weatherService := weatherservice.New(log, owClient, cache, observationRep, accuracyRep, storage)
 	// Freshing cache
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		cacheRep.FreshCache(ctx, log, owClient)
+		cacheRep.FreshCache(ctx, log, owClient, guard.Budget())
+	}()
+	// Downsampling of observations
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		observationRep.Run(ctx)
+	}()
+	// Comparing forecasts with observations
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		accuracyRep.Run(ctx)
 	}()
 	// Initialize Handler
-	handler := handler.New(log, bot, owClient, hfClient, cache)
+	handler := handler.New(cfg, log, bot, hfClient, cache, weatherService, storage, templates, guard, outbox, historyRep, observationRep, accuracyRep)
+
+	// HTTP API for dashboards
+	if len(cfg.API.Keys) > 0 && cfg.Port != "" {
+		server := api.New(cfg, log, weatherService, guard)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Run(ctx); err != nil {
+				log.Error(err.Error())
+			}
+		}()
+	}
 
 	// Start listening telegram messages
 	wg.Add(1)
@@ -79,6 +148,7 @@ func RunBot() error {
 	cache.Close()
 
 	wg.Wait()
+	storage.Close()
 	log.Info("shutdown complete")
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,20 +13,22 @@ import (
 )
 
 type WeatherCache struct {
-	client *redis.Client
-	ttl    time.Duration
-	log    *slog.Logger
+	client      *redis.Client
+	ttl         time.Duration
+	forecastTTL time.Duration
+	log         *slog.Logger
 }
 
-func NewCache(addr, password string, db int, ttl time.Duration, log *slog.Logger) *WeatherCache {
+func NewCache(addr, password string, db int, ttl, forecastTTL time.Duration, log *slog.Logger) *WeatherCache {
 	return &WeatherCache{
 		client: redis.NewClient(&redis.Options{
 			Addr:     addr,
 			Password: password,
 			DB:       db,
 		}),
-		ttl: ttl,
-		log: log,
+		ttl:         ttl,
+		forecastTTL: forecastTTL,
+		log:         log,
 	}
 }
 
@@ -68,6 +71,73 @@ func (c *WeatherCache) UpdateWeather(ctx context.Context, weather models.CacheWe
 	return nil
 }
 
+// Get forecast from cache
+func (c *WeatherCache) GetForecast(ctx context.Context, city string) (*models.CacheForecast, error) {
+	op := "redis.getforecast"
+
+	data, err := c.client.Get(ctx, forecastKey(city)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%s: %s", op, "key is not exists")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var forecast models.CacheForecast
+
+	if err := json.Unmarshal(data, &forecast); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &forecast, nil
+}
+
+// Update forecast in cache
+func (c *WeatherCache) UpdateForecast(ctx context.Context, forecast models.CacheForecast) error {
+	op := "redis.updateforecast"
+
+	forecast.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(forecast)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.client.Set(ctx, forecastKey(forecast.City), data, c.forecastTTL).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Delete weather and forecast from cache, false if there was nothing for city
+func (c *WeatherCache) DeleteWeather(ctx context.Context, city string) (bool, error) {
+	op := "redis.deleteweather"
+
+	deleted, err := c.client.Del(ctx, cityKey(city), forecastKey(city)).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted > 0, nil
+}
+
+// Cities with cached weather
+func (c *WeatherCache) Cities(ctx context.Context) ([]string, error) {
+	op := "redis.cities"
+
+	var cities []string
+	iter := c.client.Scan(ctx, 0, cityKey("*"), 0).Iterator()
+	for iter.Next(ctx) {
+		cities = append(cities, strings.TrimPrefix(iter.Val(), cityKey("")))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cities, nil
+}
+
 // Shutdown
 func (c *WeatherCache) Close() error {
 	if err := c.client.Close(); err != nil {
@@ -80,3 +150,7 @@ func (c *WeatherCache) Close() error {
 func cityKey(city string) string {
 	return fmt.Sprintf("weather:%s", city)
 }
+
+func forecastKey(city string) string {
+	return fmt.Sprintf("forecast:%s", city)
+}
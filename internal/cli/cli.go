@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/joho/godotenv"
+	"github.com/m1al04949/weatherbot/internal/app"
+	"github.com/m1al04949/weatherbot/internal/cache/redis"
+	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
+	"github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/repositories/accuracyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/observationrepository"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
+)
+
+const usage = `Использование: weatherbot [команда]
+
+Команды:
+  serve                      запустить бота (по умолчанию)
+  weather <город>            текущая погода
+  forecast <город>           прогноз на 5 дней
+  cache inspect [город]      погода в кэше, без города — список городов
+  cache flush [город]        очистить кэш города, без города — весь кэш
+  config validate            проверить конфиг из CFG_PATH
+  webhook set|delete|info    управление вебхуком Telegram по webhookurl из конфига
+
+Конфиг читается из файла CFG_PATH, переменные окружения можно задать в .env.
+`
+
+var ErrUsage = errors.New("invalid arguments")
+
+type command struct {
+	out io.Writer
+	cfg *config.Config
+	log *slog.Logger
+}
+
+// Run subcommand from args, the bot is started without subcommand
+func Run(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] == "serve" {
+		return app.RunBot()
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out, usage)
+		return nil
+	}
+
+	run, err := parse(args[0], args[1:])
+	if err != nil {
+		fmt.Fprint(out, usage)
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Variables may be set without .env
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, &command{
+		out: out,
+		cfg: cfg,
+		// Logs don't mix with command output
+		log: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	})
+}
+
+// Subcommand of name with its arguments
+func parse(name string, args []string) (func(ctx context.Context, c *command) error, error) {
+	switch {
+	case name == "weather" && len(args) > 0:
+		return func(ctx context.Context, c *command) error { return c.weather(ctx, strings.Join(args, " ")) }, nil
+	case name == "forecast" && len(args) > 0:
+		return func(ctx context.Context, c *command) error { return c.forecast(ctx, strings.Join(args, " ")) }, nil
+	case name == "cache" && len(args) > 0 && args[0] == "inspect":
+		return func(ctx context.Context, c *command) error { return c.cacheInspect(ctx, strings.Join(args[1:], " ")) }, nil
+	case name == "cache" && len(args) > 0 && args[0] == "flush":
+		return func(ctx context.Context, c *command) error { return c.cacheFlush(ctx, strings.Join(args[1:], " ")) }, nil
+	case name == "config" && len(args) == 1 && args[0] == "validate":
+		return func(ctx context.Context, c *command) error { return c.configValidate() }, nil
+	case name == "webhook" && len(args) == 1 && slices.Contains([]string{"set", "delete", "info"}, args[0]):
+		return func(ctx context.Context, c *command) error { return c.webhook(args[0]) }, nil
+	}
+
+	return nil, ErrUsage
+}
+
+// weather <city>
+func (c *command) weather(ctx context.Context, city string) error {
+	service, templates, closeFn, err := c.service()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	current, err := service.Current(ctx, weatherservice.Query{City: city, NoRecord: true})
+	if err != nil {
+		return err
+	}
+
+	text, err := templates.Render(format.TemplateWeather, format.Current{Name: current.Location.Name, Weather: current.Weather})
+	if err != nil {
+		return err
+	}
+
+	source := "сервис погоды"
+	if current.Cached {
+		source = "кэш"
+	}
+	fmt.Fprintf(c.out, "%s\n\nИсточник: %s, обновлено %s\n",
+		format.PlainText(text), source, current.UpdatedAt.Format(time.DateTime))
+
+	return nil
+}
+
+// forecast <city>
+func (c *command) forecast(ctx context.Context, city string) error {
+	service, templates, closeFn, err := c.service()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := service.Forecast(ctx, weatherservice.Query{City: city, NoRecord: true})
+	if err != nil {
+		return err
+	}
+
+	today, days := forecast.Split(result.Items, time.Now())
+	text, err := templates.Render(format.TemplateForecast, format.Forecast{
+		Name:  result.Location.Name,
+		Today: today,
+		Days:  days,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, format.PlainText(text))
+
+	return nil
+}
+
+// cache inspect [city]
+func (c *command) cacheInspect(ctx context.Context, city string) error {
+	cache := c.cache()
+	defer cache.Close()
+
+	if city != "" {
+		weather, err := cache.GetWeather(ctx, city)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(weather, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, string(data))
+		return nil
+	}
+
+	cities, err := cache.Cities(ctx)
+	if err != nil {
+		return err
+	}
+	if len(cities) == 0 {
+		fmt.Fprintln(c.out, "Кэш пуст")
+		return nil
+	}
+
+	for _, city := range cities {
+		weather, err := cache.GetWeather(ctx, city)
+		if err != nil {
+			// Key may expire between scan and get
+			continue
+		}
+		fmt.Fprintf(c.out, "%s\t%.1f°C\tобновлено %s\n",
+			city, weather.Weather.Temp, weather.UpdatedAt.Format(time.DateTime))
+	}
+
+	return nil
+}
+
+// cache flush [city]
+func (c *command) cacheFlush(ctx context.Context, city string) error {
+	cache := c.cache()
+	defer cache.Close()
+
+	cities := []string{city}
+	if city == "" {
+		var err error
+		if cities, err = cache.Cities(ctx); err != nil {
+			return err
+		}
+	}
+
+	var deleted int
+	for _, city := range cities {
+		ok, err := cache.DeleteWeather(ctx, city)
+		if err != nil {
+			return err
+		}
+		if ok {
+			deleted++
+		}
+	}
+
+	fmt.Fprintf(c.out, "Удалено из кэша: %d\n", deleted)
+
+	return nil
+}
+
+// config validate
+func (c *command) configValidate() error {
+	if err := c.cfg.Validate(); err != nil {
+		return err
+	}
+	if _, err := format.New(c.cfg.Templates); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Конфиг %s корректен\n", os.Getenv("CFG_PATH"))
+
+	return nil
+}
+
+// webhook set|delete|info
+func (c *command) webhook(action string) error {
+	bot, err := tgbotapi.NewBotAPI(c.cfg.BotToken)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "set":
+		if c.cfg.WebhookURL == "" {
+			return errors.New("webhookurl is not set in config")
+		}
+		webhook, err := tgbotapi.NewWebhook(c.cfg.WebhookURL)
+		if err != nil {
+			return err
+		}
+		if _, err := bot.Request(webhook); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Вебхук установлен: %s\nПока он установлен, бот не получает обновления через serve\n", c.cfg.WebhookURL)
+	case "delete":
+		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "Вебхук удален")
+	case "info":
+		info, err := bot.GetWebhookInfo()
+		if err != nil {
+			return err
+		}
+		if info.URL == "" {
+			fmt.Fprintln(c.out, "Вебхук не установлен")
+			return nil
+		}
+		fmt.Fprintf(c.out, "URL: %s\nОжидают доставки: %d\n", info.URL, info.PendingUpdateCount)
+		if info.LastErrorDate != 0 {
+			fmt.Fprintf(c.out, "Последняя ошибка: %s (%s)\n",
+				info.LastErrorMessage, time.Unix(int64(info.LastErrorDate), 0).Format(time.DateTime))
+		}
+	}
+
+	return nil
+}
+
+func (c *command) cache() *redis.WeatherCache {
+	return redis.NewCache(
+		c.cfg.Cache.Address, c.cfg.Cache.Password, c.cfg.Cache.DB,
+		time.Duration(c.cfg.Cache.TTL)*time.Minute, time.Duration(c.cfg.Cache.ForecastTTL)*time.Minute, c.log)
+}
+
+// Shared cache of the bot is only read, so that debug requests don't change what users get
+type readOnlyCache struct {
+	*redis.WeatherCache
+}
+
+func (readOnlyCache) UpdateWeather(ctx context.Context, weather models.CacheWeather) error {
+	return nil
+}
+
+func (readOnlyCache) UpdateForecast(ctx context.Context, forecast models.CacheForecast) error {
+	return nil
+}
+
+// Weather service of the bot that changes nothing: cache is only read,
+// observations and forecasts aren't recorded. Provider calls aren't limited
+func (c *command) service() (*weatherservice.WeatherService, *format.Templates, func(), error) {
+	templates, err := format.New(c.cfg.Templates)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	storage, err := sqlite.New(c.cfg.Storage.Path, c.log)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cache := c.cache()
+
+	service := weatherservice.New(
+		c.log, openweather.New(c.cfg.OpenWeatherKey, openweather.Transport(c.cfg.OpenWeather)), readOnlyCache{cache},
+		observationrepository.New(c.cfg.Observations, c.log, storage),
+		accuracyrepository.New(c.log, storage),
+		storage,
+	)
+
+	closeFn := func() {
+		cache.Close()
+		storage.Close()
+	}
+
+	return service, templates, closeFn, nil
+}
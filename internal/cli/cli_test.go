@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{name: "help", args: []string{"help"}},
+		{name: "short help flag", args: []string{"-h"}},
+		{name: "help flag", args: []string{"--help"}},
+		{name: "unknown command", args: []string{"status"}, want: ErrUsage},
+		{name: "weather without city", args: []string{"weather"}, want: ErrUsage},
+		{name: "forecast without city", args: []string{"forecast"}, want: ErrUsage},
+		{name: "cache without action", args: []string{"cache"}, want: ErrUsage},
+		{name: "unknown cache action", args: []string{"cache", "drop", "Москва"}, want: ErrUsage},
+		{name: "config without action", args: []string{"config"}, want: ErrUsage},
+		{name: "config validate with path", args: []string{"config", "validate", "config.yaml"}, want: ErrUsage},
+		{name: "unknown webhook action", args: []string{"webhook", "restart"}, want: ErrUsage},
+		{name: "webhook with url", args: []string{"webhook", "set", "https://example.com"}, want: ErrUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Config must not be read for usage
+			t.Setenv("CFG_PATH", "")
+
+			var out bytes.Buffer
+			if err := Run(tt.args, &out); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if !strings.HasPrefix(out.String(), "Использование") {
+				t.Errorf("usage is not printed: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, args := range [][]string{
+		{"weather", "Нижний", "Новгород"},
+		{"forecast", "Москва"},
+		{"cache", "inspect"},
+		{"cache", "inspect", "Москва"},
+		{"cache", "flush"},
+		{"config", "validate"},
+		{"webhook", "set"},
+		{"webhook", "delete"},
+		{"webhook", "info"},
+	} {
+		if run, err := parse(args[0], args[1:]); err != nil || run == nil {
+			t.Errorf("%q: got %v", args, err)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, extra string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "bottoken: token\nopenweatherkey: key\ncache:\n  address: localhost:6379\n  ttl: 10\nbroker:\n  addrs: [localhost:9092]\n" + extra
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CFG_PATH", path)
+}
+
+func TestConfigValidate(t *testing.T) {
+	writeConfig(t, "")
+
+	var out bytes.Buffer
+	if err := Run([]string{"config", "validate"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "корректен") {
+		t.Errorf("got %q", out.String())
+	}
+
+	writeConfig(t, "port: http\n")
+	if err := Run([]string{"config", "validate"}, &out); err == nil || !strings.Contains(err.Error(), "port") {
+		t.Errorf("got %v, want port error", err)
+	}
+
+	// Offline modes need an explicit fixtures directory
+	writeConfig(t, "openweather:\n  mode: replay\n")
+	if err := Run([]string{"config", "validate"}, &out); err == nil || !strings.Contains(err.Error(), "openweather.fixtures") {
+		t.Errorf("got %v, want fixtures error", err)
+	}
+
+	t.Setenv("CFG_PATH", "")
+	if err := Run([]string{"config", "validate"}, &out); err == nil || errors.Is(err, ErrUsage) {
+		t.Errorf("got %v, want config error", err)
+	}
+}
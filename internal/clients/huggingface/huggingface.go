@@ -2,64 +2,186 @@ package huggingface
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/condition"
+	"github.com/m1al04949/weatherbot/internal/models"
 )
 
+// Upper bound of waiting for model loading
+const maxRetryWait = 60 * time.Second
+
 type HuggingFaceClient struct {
-	apiKey string
+	apiKey  string
+	url     string
+	retries int
+	client  *http.Client
+
+	mu     sync.Mutex
+	images map[string][]byte // by condition and season
+}
+
+type request struct {
+	Inputs string `json:"inputs"`
 }
 
-func New(apiKey string) *HuggingFaceClient {
+// Body of 503 response while model is loading
+type loadingResponse struct {
+	Error         string  `json:"error"`
+	EstimatedTime float64 `json:"estimated_time"`
+}
+
+func New(cfg config.HuggingFace) *HuggingFaceClient {
 	return &HuggingFaceClient{
-		apiKey: apiKey,
+		apiKey:  cfg.Key,
+		url:     fmt.Sprintf("%s/%s", strings.TrimSuffix(cfg.URL, "/"), cfg.Model),
+		retries: cfg.Retries,
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		images:  make(map[string][]byte),
 	}
 }
 
-func (hf *HuggingFaceClient) GenerateWithHuggingFace(text string) ([]byte, error) {
-	op := "clients.huggingface.generatewithhuggingface"
-	url := "https://api-inference.huggingface.co/models/stabilityai/stable-diffusion-xl-base-1.0"
-	// url := "https://api-inference.huggingface.co/models/stabilityai/stable-diffusion-3-medium-diffusers"
+// Illustration of weather, cached per condition and season
+func (hf *HuggingFaceClient) Illustrate(ctx context.Context, weather models.Weather) ([]byte, error) {
+	op := "clients.huggingface.illustrate"
+
+	sky := skyOf(weather.Code)
+	season := seasonOf(weather.Date)
+	key := sky + ":" + season
+
+	hf.mu.Lock()
+	image, ok := hf.images[key]
+	hf.mu.Unlock()
+	if ok {
+		return image, nil
+	}
 
 	prompt := fmt.Sprintf(
-		"Создай красивое изображение прогноза погоды. Стиль: плоский дизайн, пастельные тона. %s",
-		text)
+		"Weather illustration: %s, %s day, city landscape. Style: flat design, pastel colors, no text.",
+		sky, season)
 
-	req, err := http.NewRequest(
-		"POST", url,
-		bytes.NewBuffer([]byte(fmt.Sprintf(`{""text":"%s"}`, prompt))),
-	)
+	image, err := hf.GenerateWithHuggingFace(ctx, prompt)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// Header
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", hf.apiKey))
+	hf.mu.Lock()
+	hf.images[key] = image
+	hf.mu.Unlock()
 
-	// Do request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return image, nil
+}
+
+func (hf *HuggingFaceClient) GenerateWithHuggingFace(ctx context.Context, prompt string) ([]byte, error) {
+	op := "clients.huggingface.generatewithhuggingface"
+
+	payload, err := json.Marshal(request{Inputs: prompt})
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, hf.url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Header
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "image/png")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", hf.apiKey))
+
+		// Do request
+		resp, err := hf.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Model is loading, wait and retry
+		if resp.StatusCode == http.StatusServiceUnavailable && attempt < hf.retries {
+			wait := retryAfter(resp.Header, body)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		// Check status
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s API returned %d: %s", op, resp.StatusCode, string(body))
+		}
+
+		if len(body) == 0 {
+			return nil, fmt.Errorf("%s: empty image", op)
+		}
+
+		return body, nil
 	}
-	// Check status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s API returned %d: %s", op, resp.StatusCode, string(body))
+}
+
+// Wait time from Retry-After header or estimated loading time
+func retryAfter(header http.Header, body []byte) time.Duration {
+	wait := time.Second
+
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else {
+		var loading loadingResponse
+		if err := json.Unmarshal(body, &loading); err == nil && loading.EstimatedTime > 0 {
+			wait = time.Duration(loading.EstimatedTime * float64(time.Second))
+		}
 	}
 
-	// Reading bin data
-	imageBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	return min(wait, maxRetryWait)
+}
+
+// Prompt words of condition code
+func skyOf(code int) string {
+	switch condition.GroupOf(code) {
+	case condition.Thunderstorm:
+		return "thunderstorm"
+	case condition.Snow:
+		return "snow"
+	case condition.Rain, condition.Drizzle:
+		return "rain"
+	case condition.Atmosphere:
+		return "fog"
+	case condition.Clear:
+		return "clear sky"
 	}
+	if code == 804 {
+		return "overcast"
+	}
+
+	return "partly cloudy"
+}
 
-	return imageBytes, nil
+func seasonOf(date time.Time) string {
+	switch date.Month() {
+	case time.December, time.January, time.February:
+		return "winter"
+	case time.March, time.April, time.May:
+		return "spring"
+	case time.June, time.July, time.August:
+		return "summer"
+	default:
+		return "autumn"
+	}
 }
@@ -0,0 +1,174 @@
+package huggingface
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// Fake inference API: answers 503 "model loading" for the first loading requests
+func fakeServer(t *testing.T, loading int32, calls *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+
+		if r.Method != http.MethodPost || r.URL.Path != "/models/test/model" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("authorization: got %q", got)
+		}
+
+		var payload request
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("malformed payload: %s", err)
+		}
+		if payload.Inputs == "" {
+			t.Error("empty inputs")
+		}
+
+		if n <= loading {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"Model test/model is currently loading","estimated_time":0.01}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngHeader)
+	}))
+}
+
+func newClient(url string, retries int) *HuggingFaceClient {
+	return New(config.HuggingFace{
+		Key:     "secret",
+		URL:     url + "/models/",
+		Model:   "test/model",
+		Retries: retries,
+		Timeout: 5,
+	})
+}
+
+func TestGenerateEscapesPrompt(t *testing.T) {
+	var calls int32
+	server := fakeServer(t, 0, &calls)
+	defer server.Close()
+
+	image, err := newClient(server.URL, 0).GenerateWithHuggingFace(context.Background(), `"quoted" prompt \ with slash`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(image) != string(pngHeader) {
+		t.Errorf("got image %q", image)
+	}
+}
+
+func TestGenerateRetriesWhileLoading(t *testing.T) {
+	var calls int32
+	server := fakeServer(t, 2, &calls)
+	defer server.Close()
+
+	if _, err := newClient(server.URL, 2).GenerateWithHuggingFace(context.Background(), "prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls, want 3", calls)
+	}
+}
+
+func TestGenerateGivesUpAfterRetries(t *testing.T) {
+	var calls int32
+	server := fakeServer(t, 5, &calls)
+	defer server.Close()
+
+	if _, err := newClient(server.URL, 1).GenerateWithHuggingFace(context.Background(), "prompt"); err == nil {
+		t.Fatal("expected error while model is loading")
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+func TestIllustrateCachesByConditionAndSeason(t *testing.T) {
+	var calls int32
+	server := fakeServer(t, 0, &calls)
+	defer server.Close()
+
+	client := newClient(server.URL, 0)
+	ctx := context.Background()
+	january := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	july := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, weather := range []models.Weather{
+		{Code: 600, Description: "небольшой снег", Date: january},
+		{Code: 601, Description: "snow", Date: january.AddDate(0, 1, 0)}, // same condition and season
+		{Code: 600, Description: "небольшой снег", Date: july},
+		{Code: 800, Description: "ясно", Date: july},
+	} {
+		if _, err := client.Illustrate(ctx, weather); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("got %d calls, want 3", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   time.Duration
+	}{
+		{"header", "7", `{"estimated_time":20}`, 7 * time.Second},
+		{"estimated time", "", `{"estimated_time":2.5}`, 2500 * time.Millisecond},
+		{"capped", "", `{"estimated_time":600}`, maxRetryWait},
+		{"default", "", `not json`, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Retry-After", tt.header)
+			}
+			if got := retryAfter(header, []byte(tt.body)); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkyOf(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{code: 211, want: "thunderstorm"},
+		{code: 301, want: "rain"},
+		{code: 502, want: "rain"},
+		{code: 601, want: "snow"},
+		{code: 741, want: "fog"},
+		{code: 800, want: "clear sky"},
+		{code: 802, want: "partly cloudy"},
+		{code: 804, want: "overcast"},
+		{code: 0, want: "partly cloudy"},
+	}
+
+	for _, tt := range tests {
+		if got := skyOf(tt.code); got != tt.want {
+			t.Errorf("%d: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
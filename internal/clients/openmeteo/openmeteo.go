@@ -0,0 +1,166 @@
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// Reanalysis gets to the archive with a delay, newer days are missing or incomplete
+	ArchiveDays = 5
+)
+
+var ErrNoData = errors.New("no data")
+
+// Client of Open-Meteo historical weather API, no key is required
+type OpenMeteoClient struct {
+	url    string
+	client *http.Client
+}
+
+type archiveResponse struct {
+	Daily struct {
+		// Values of days not in the archive yet are null
+		Time          []string   `json:"time"`
+		WeatherCode   []*int     `json:"weather_code"`
+		MaxTemp       []*float64 `json:"temperature_2m_max"`
+		MinTemp       []*float64 `json:"temperature_2m_min"`
+		Precipitation []*float64 `json:"precipitation_sum"`
+		MaxSpeed      []*float64 `json:"wind_speed_10m_max"`
+	} `json:"daily"`
+}
+
+func New(cfg config.History) *OpenMeteoClient {
+	return &OpenMeteoClient{
+		url:    cfg.URL,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+	}
+}
+
+// Observed weather of the day, date is taken in its location.
+// ErrNoData is returned for days not in the archive yet
+func (o *OpenMeteoClient) Day(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error) {
+	op := "clients.openmeteo.day"
+	url := "%s?latitude=%f&longitude=%f&start_date=%s&end_date=%s&timezone=auto&wind_speed_unit=ms" +
+		"&daily=weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum,wind_speed_10m_max"
+
+	day := date.Format(dateLayout)
+	if !Archived(date, time.Now()) {
+		return nil, fmt.Errorf("%s: %w for %s yet", op, ErrNoData, day)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(url, o.url, lat, lon, day, day), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: bad status %d", op, resp.StatusCode)
+	}
+
+	var archive archiveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	daily := archive.Daily
+	if len(daily.Time) == 0 || !first(daily.WeatherCode) || !first(daily.MaxTemp) ||
+		!first(daily.MinTemp) || !first(daily.Precipitation) || !first(daily.MaxSpeed) {
+		return nil, fmt.Errorf("%s: %w for %s", op, ErrNoData, day)
+	}
+
+	code, description := condition(*daily.WeatherCode[0])
+
+	return &models.DailyForecast{
+		Date:          time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+		MinTemp:       *daily.MinTemp[0],
+		MaxTemp:       *daily.MaxTemp[0],
+		Code:          code,
+		Description:   description,
+		Precipitation: *daily.Precipitation[0],
+		MaxSpeed:      *daily.MaxSpeed[0],
+	}, nil
+}
+
+// Day is old enough to be complete in the archive
+func Archived(date, now time.Time) bool {
+	now = now.In(date.Location())
+	latest := time.Date(now.Year(), now.Month(), now.Day()-ArchiveDays, 0, 0, 0, 0, date.Location())
+
+	return !date.After(latest)
+}
+
+// Value of the first day is present
+func first[T any](values []*T) bool {
+	return len(values) > 0 && values[0] != nil
+}
+
+// OpenWeather condition code and description of WMO weather code
+func condition(wmo int) (int, string) {
+	switch wmo {
+	case 0:
+		return 800, "ясно"
+	case 1:
+		return 801, "преимущественно ясно"
+	case 2:
+		return 802, "переменная облачность"
+	case 3:
+		return 804, "пасмурно"
+	case 45, 48:
+		return 741, "туман"
+	case 51:
+		return 300, "слабая морось"
+	case 53:
+		return 301, "морось"
+	case 55:
+		return 302, "сильная морось"
+	case 56, 57:
+		return 511, "ледяная морось"
+	case 61:
+		return 500, "небольшой дождь"
+	case 63:
+		return 501, "дождь"
+	case 65:
+		return 502, "сильный дождь"
+	case 66, 67:
+		return 511, "ледяной дождь"
+	case 71:
+		return 600, "небольшой снег"
+	case 73:
+		return 601, "снег"
+	case 75:
+		return 602, "сильный снег"
+	case 77:
+		return 600, "снежные зерна"
+	case 80:
+		return 520, "небольшой ливень"
+	case 81:
+		return 521, "ливень"
+	case 82:
+		return 522, "сильный ливень"
+	case 85:
+		return 620, "небольшой снегопад"
+	case 86:
+		return 622, "сильный снегопад"
+	case 95:
+		return 211, "гроза"
+	case 96, 99:
+		return 202, "гроза с градом"
+	default:
+		return 0, "нет данных"
+	}
+}
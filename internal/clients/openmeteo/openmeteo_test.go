@@ -0,0 +1,146 @@
+package openmeteo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+)
+
+func TestCondition(t *testing.T) {
+	tests := []struct {
+		wmo         int
+		code        int
+		description string
+	}{
+		{wmo: 0, code: 800, description: "ясно"},
+		{wmo: 3, code: 804, description: "пасмурно"},
+		{wmo: 48, code: 741, description: "туман"},
+		{wmo: 57, code: 511, description: "ледяная морось"},
+		{wmo: 63, code: 501, description: "дождь"},
+		{wmo: 75, code: 602, description: "сильный снег"},
+		{wmo: 82, code: 522, description: "сильный ливень"},
+		{wmo: 86, code: 622, description: "сильный снегопад"},
+		{wmo: 99, code: 202, description: "гроза с градом"},
+		{wmo: 4, code: 0, description: "нет данных"},
+	}
+
+	for _, tt := range tests {
+		code, description := condition(tt.wmo)
+		if code != tt.code || description != tt.description {
+			t.Errorf("%d: got %d %q, want %d %q", tt.wmo, code, description, tt.code, tt.description)
+		}
+	}
+}
+
+func newClient(t *testing.T, body string) *OpenMeteoClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start_date") != "2024-03-15" || r.URL.Query().Get("latitude") != "55.750000" {
+			t.Errorf("request: %s", r.URL)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return New(config.History{URL: server.URL, Timeout: 5})
+}
+
+func TestDay(t *testing.T) {
+	client := newClient(t, `{"daily": {
+		"time": ["2024-03-15"],
+		"weather_code": [71],
+		"temperature_2m_max": [1.5],
+		"temperature_2m_min": [-4.2],
+		"precipitation_sum": [2.1],
+		"wind_speed_10m_max": [6.3]
+	}}`)
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	day, err := client.Day(context.Background(), 55.75, 37.62, time.Date(2024, 3, 15, 14, 30, 0, 0, moscow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !day.Date.Equal(time.Date(2024, 3, 15, 0, 0, 0, 0, moscow)) {
+		t.Errorf("date: got %s", day.Date)
+	}
+	if day.MinTemp != -4.2 || day.MaxTemp != 1.5 || day.Precipitation != 2.1 || day.MaxSpeed != 6.3 {
+		t.Errorf("got %+v", day)
+	}
+	if day.Code != 600 || day.Description != "небольшой снег" {
+		t.Errorf("condition: got %d %q", day.Code, day.Description)
+	}
+}
+
+func TestDayWithoutData(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "nulls", body: `{"daily": {
+			"time": ["2024-03-15"],
+			"weather_code": [null],
+			"temperature_2m_max": [null],
+			"temperature_2m_min": [null],
+			"precipitation_sum": [null],
+			"wind_speed_10m_max": [null]
+		}}`},
+		{name: "one null", body: `{"daily": {
+			"time": ["2024-03-15"],
+			"weather_code": [0],
+			"temperature_2m_max": [3],
+			"temperature_2m_min": [1],
+			"precipitation_sum": [0],
+			"wind_speed_10m_max": [null]
+		}}`},
+		{name: "empty", body: `{"daily": {"time": []}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newClient(t, tt.body).Day(context.Background(), 55.75, 37.62, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+			if !errors.Is(err, ErrNoData) {
+				t.Errorf("got %v, want %v", err, ErrNoData)
+			}
+		})
+	}
+}
+
+func TestDayNotArchived(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("recent day must not be requested: %s", r.URL)
+	}))
+	defer server.Close()
+
+	client := New(config.History{URL: server.URL, Timeout: 5})
+	if _, err := client.Day(context.Background(), 55.75, 37.62, time.Now().AddDate(0, 0, -1)); !errors.Is(err, ErrNoData) {
+		t.Errorf("got %v, want %v", err, ErrNoData)
+	}
+}
+
+func TestArchived(t *testing.T) {
+	now := time.Date(2024, 3, 20, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), want: true},
+		{date: time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC), want: false},
+		{date: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), want: false},
+		// It's already 21 March in Tokyo
+		{date: time.Date(2024, 3, 16, 0, 0, 0, 0, time.FixedZone("JST", 9*60*60)), want: true},
+	}
+
+	for _, tt := range tests {
+		if got := Archived(tt.date, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
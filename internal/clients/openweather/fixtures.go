@@ -0,0 +1,115 @@
+package openweather
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+)
+
+// Modes of config.OpenWeather
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Response for the request was never recorded
+var ErrNoFixture = errors.New("fixture is not recorded")
+
+// Transport of API requests: recorded responses stand in for the network in replay mode
+// and copies of real ones are saved in record mode, key is never part of fixture
+type Fixtures struct {
+	dir    string
+	record bool
+	next   http.RoundTripper
+}
+
+func NewFixtures(dir string, record bool, next http.RoundTripper) *Fixtures {
+	return &Fixtures{
+		dir:    dir,
+		record: record,
+		next:   next,
+	}
+}
+
+// Transport for the mode from config, nil is the network
+func Transport(cfg config.OpenWeather) http.RoundTripper {
+	switch cfg.Mode {
+	case ModeReplay:
+		return NewFixtures(cfg.Fixtures, false, nil)
+	case ModeRecord:
+		return NewFixtures(cfg.Fixtures, true, http.DefaultTransport)
+	}
+
+	return nil
+}
+
+func (f *Fixtures) RoundTrip(req *http.Request) (*http.Response, error) {
+	op := "clients.openweather.roundtrip"
+
+	path := filepath.Join(f.dir, FixtureName(req.URL))
+
+	if !f.record {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w: %s", op, ErrNoFixture, path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// Errors are passed through, only answers worth replaying are saved
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resp, nil
+}
+
+// File name of response: endpoint and sorted parameters without the key,
+// e.g. data-2.5-forecast_lang=ru&lat=55.750000&lon=37.620000&units=metric.json
+func FixtureName(u *url.URL) string {
+	params := u.Query()
+	params.Del("appid")
+
+	endpoint := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", "-")
+
+	return endpoint + "_" + params.Encode() + ".json"
+}
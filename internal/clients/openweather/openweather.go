@@ -2,60 +2,86 @@ package openweather
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"time"
 
 	"github.com/m1al04949/weatherbot/internal/models"
 )
 
+// Name of the weather provider in forecast accuracy stats
+const Provider = "openweather"
+
+// Geocoding found nothing
+var ErrNotFound = errors.New("location not found")
+
 type OpenWeatherClient struct {
 	apiKey string
+	client *http.Client
 }
 
-func New(apiKey string) *OpenWeatherClient {
+// Transport serves requests, nil is the network, see Transport for fixtures
+func New(apiKey string, transport http.RoundTripper) *OpenWeatherClient {
 	return &OpenWeatherClient{
 		apiKey: apiKey,
+		client: &http.Client{Transport: transport},
 	}
 }
 
 func (o *OpenWeatherClient) Coordinates(city string) (*models.Cordinates, error) {
 	op := "clients.openwather.coordinates"
-	url := "http://api.openweathermap.org/geo/1.0/direct?q=%s&limit=5&appid=%s"
 
-	resp, err := http.Get(fmt.Sprintf(url, city, o.apiKey))
+	locations, err := o.Locations(city, 1)
+	if err != nil {
+		return &models.Cordinates{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.Cordinates{
+		Lat: locations[0].Lat,
+		Lon: locations[0].Lon,
+	}, nil
+}
+
+// Geocoding candidates for the city name
+func (o *OpenWeatherClient) Locations(city string, limit int) ([]models.CordinatesResponse, error) {
+	op := "clients.openwather.locations"
+	url := "http://api.openweathermap.org/geo/1.0/direct?q=%s&limit=%d&appid=%s"
+
+	resp, err := o.client.Get(fmt.Sprintf(url, neturl.QueryEscape(city), limit, o.apiKey))
 	if err != nil {
-		return &models.Cordinates{}, fmt.Errorf("error get coordinates in %s: %w", op, err)
+		return nil, fmt.Errorf("error get coordinates in %s: %w", op, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return &models.Cordinates{}, fmt.Errorf("error bad status in %s: %d", op, resp.StatusCode)
+		return nil, fmt.Errorf("error bad status in %s: %d", op, resp.StatusCode)
 	}
 
 	var cordinatesResp []models.CordinatesResponse
 
 	err = json.NewDecoder(resp.Body).Decode(&cordinatesResp)
 	if err != nil {
-		return &models.Cordinates{}, fmt.Errorf("error unmarshal response in %s: %w", op, err)
+		return nil, fmt.Errorf("error unmarshal response in %s: %w", op, err)
 	}
 
 	if len(cordinatesResp) == 0 {
-		return &models.Cordinates{}, fmt.Errorf("error empty coordinates in %s", op)
+		return nil, fmt.Errorf("error empty coordinates in %s: %w", op, ErrNotFound)
 	}
 
-	return &models.Cordinates{
-		Lat: cordinatesResp[0].Lat,
-		Lon: cordinatesResp[0].Lon,
-	}, nil
+	return cordinatesResp, nil
 }
 
 func (o *OpenWeatherClient) CurrentWeather(lat, lon float64) (*models.Weather, error) {
 	op := "clients.openwather.currentweather"
 	url := "https://api.openweathermap.org/data/2.5/weather?lat=%f&lon=%f&appid=%s&units=metric&lang=ru"
 
-	resp, err := http.Get(fmt.Sprintf(url, lat, lon, o.apiKey))
+	resp, err := o.client.Get(fmt.Sprintf(url, lat, lon, o.apiKey))
 	if err != nil {
 		return &models.Weather{}, fmt.Errorf("error get current weather in %s: %w", op, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return &models.Weather{}, fmt.Errorf("error bad status in %s: %d", op, resp.StatusCode)
@@ -68,22 +94,32 @@ func (o *OpenWeatherClient) CurrentWeather(lat, lon float64) (*models.Weather, e
 		return &models.Weather{}, fmt.Errorf("error unmarshal response in %s: %w", op, err)
 	}
 
-	return &models.Weather{
-		Description: weatherResp.Weather[0].Description,
-		Temp:        weatherResp.Main.Temp,
-		Humidity:    weatherResp.Main.Humidity,
-		Speed:       weatherResp.Wind.Speed,
-	}, nil
+	location := time.FixedZone("", weatherResp.Timezone)
+
+	weather := &models.Weather{
+		Date:     time.Unix(weatherResp.Date, 0).In(location),
+		Temp:     weatherResp.Main.Temp,
+		Humidity: weatherResp.Main.Humidity,
+		Speed:    weatherResp.Wind.Speed,
+	}
+	if len(weatherResp.Weather) > 0 {
+		weather.Code = weatherResp.Weather[0].ID
+		weather.Icon = weatherResp.Weather[0].Icon
+		weather.Description = weatherResp.Weather[0].Description
+	}
+
+	return weather, nil
 }
 
 func (o *OpenWeatherClient) ForecastWeather(lat, lon float64) (*[]models.Weather, error) {
 	op := "clients.openwather.forecastweather"
 	url := "https://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&appid=%s&units=metric&lang=ru"
 
-	resp, err := http.Get(fmt.Sprintf(url, lat, lon, o.apiKey))
+	resp, err := o.client.Get(fmt.Sprintf(url, lat, lon, o.apiKey))
 	if err != nil {
 		return &[]models.Weather{}, fmt.Errorf("error get forecast weather in %s: %w", op, err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return &[]models.Weather{}, fmt.Errorf("error bad status in %s: %d", op, resp.StatusCode)
@@ -99,17 +135,22 @@ func (o *OpenWeatherClient) ForecastWeather(lat, lon float64) (*[]models.Weather
 		return &[]models.Weather{}, fmt.Errorf("error unmarshal response in %s: %w", op, err)
 	}
 
+	location := time.FixedZone("", forecastWeatherResp.City.Timezone)
+
 	for _, item := range forecastWeatherResp.List {
 		weather := models.Weather{
-			Date:        item.Date, // или item.DtTxt, в зависимости от вашего API
-			Description: "",
-			Temp:        item.Main.Temp,
-			Humidity:    item.Main.Humidity,
-			Speed:       item.Wind.Speed,
+			Date:          time.Unix(item.Date, 0).In(location),
+			Description:   "",
+			Temp:          item.Main.Temp,
+			Humidity:      item.Main.Humidity,
+			Speed:         item.Wind.Speed,
+			Precipitation: item.Rain.Volume + item.Snow.Volume,
 		}
 
 		// Берем первое описание погоды (если массив weather не пустой)
 		if len(item.Weather) > 0 {
+			weather.Code = item.Weather[0].ID
+			weather.Icon = item.Weather[0].Icon
 			weather.Description = item.Weather[0].Description
 		}
 
@@ -0,0 +1,170 @@
+package openweather
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Moscow as geocoded in testdata
+const (
+	lat = 55.7504461
+	lon = 37.6174943
+)
+
+func replayClient() *OpenWeatherClient {
+	return New("secret", NewFixtures("testdata", false, nil))
+}
+
+func TestLocations(t *testing.T) {
+	client := replayClient()
+
+	cord, err := client.Coordinates("Москва")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cord.Lat != lat || cord.Lon != lon {
+		t.Errorf("got %+v", cord)
+	}
+
+	if _, err := client.Coordinates("Нигде"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestCurrentWeather(t *testing.T) {
+	weather, err := replayClient().CurrentWeather(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if weather.Temp != 7.4 || weather.Humidity != 87 || weather.Speed != 4.2 {
+		t.Errorf("got %+v", weather)
+	}
+	if weather.Code != 500 || weather.Icon != "10d" || weather.Description != "небольшой дождь" {
+		t.Errorf("condition: got %+v", weather)
+	}
+	if _, offset := weather.Date.Zone(); offset != 3*60*60 || weather.Date.Hour() != 12 {
+		t.Errorf("date: got %s", weather.Date)
+	}
+}
+
+func TestForecastWeather(t *testing.T) {
+	items, err := replayClient().ForecastWeather(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*items) != 8 {
+		t.Fatalf("items: got %d, want 8", len(*items))
+	}
+
+	first := (*items)[0]
+	if want := time.Date(2025, 10, 20, 3, 0, 0, 0, time.FixedZone("", 3*60*60)); !first.Date.Equal(want) || first.Date.Hour() != 3 {
+		t.Errorf("date: got %s, want %s in the city's timezone", first.Date, want)
+	}
+	if first.Temp != 4.1 || first.Humidity != 90 || first.Speed != 2.1 || first.Precipitation != 0 {
+		t.Errorf("first: got %+v", first)
+	}
+
+	tests := []struct {
+		index         int
+		code          int
+		icon          string
+		precipitation float64
+	}{
+		{index: 4, code: 501, icon: "10d", precipitation: 1.87}, // rain
+		{index: 6, code: 600, icon: "13n", precipitation: 0.6},  // snow
+		{index: 7, code: 616, icon: "13n", precipitation: 0.32}, // both
+	}
+	for _, tt := range tests {
+		item := (*items)[tt.index]
+		if item.Code != tt.code || item.Icon != tt.icon || item.Description == "" {
+			t.Errorf("item %d: got %+v", tt.index, item)
+		}
+		if diff := item.Precipitation - tt.precipitation; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("item %d: precipitation %v, want %v", tt.index, item.Precipitation, tt.precipitation)
+		}
+	}
+}
+
+func TestReplayWithoutFixture(t *testing.T) {
+	_, err := replayClient().CurrentWeather(0, 0)
+	if !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("got %v, want %v", err, ErrNoFixture)
+	}
+	if !strings.Contains(err.Error(), "data-2.5-weather_lang=ru&lat=0.000000&lon=0.000000&units=metric.json") {
+		t.Errorf("error must name the missing file: %v", err)
+	}
+}
+
+type transportFunc func(req *http.Request) (*http.Response, error)
+
+func (f transportFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecord(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", FixtureName(&url.URL{
+		Path:     "/data/2.5/weather",
+		RawQuery: "lat=55.750446&lon=37.617494&units=metric&lang=ru",
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("appid") != "secret" {
+			t.Errorf("key is not sent: %s", r.URL)
+		}
+		if r.URL.Query().Get("lat") != "55.750446" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	// Real API is replaced by the test server
+	next := transportFunc(func(req *http.Request) (*http.Response, error) {
+		target, _ := url.Parse(server.URL)
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	dir := t.TempDir()
+	recorded, err := New("secret", NewFixtures(dir, true, next)).CurrentWeather(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed requests aren't recorded
+	if _, err := New("secret", NewFixtures(dir, true, next)).CurrentWeather(1, 1); err == nil {
+		t.Error("error status must be passed through")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || strings.Contains(files[0].Name(), "secret") {
+		t.Fatalf("recorded files: %v", files)
+	}
+
+	replayed, err := New("other", NewFixtures(dir, false, nil)).CurrentWeather(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed.Date.Equal(recorded.Date) {
+		t.Errorf("date: replayed %s, recorded %s", replayed.Date, recorded.Date)
+	}
+	replayed.Date = recorded.Date
+	if *replayed != *recorded {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+}
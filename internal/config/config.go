@@ -1,22 +1,39 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Env            string `yaml:"env" env:"ENV" end-default:"local"`
-	BotToken       string `yaml:"bottoken" env-required:"true"`
-	OpenWeatherKey string `yaml:"openweatherkey" env-required:"true"`
-	HuggingFaceKey string `yaml:"huggingfacekey" env-required:"true"`
-	WebhookURL     string `yaml:"webhookurl"`
-	Port           string `yaml:"port"`
-	Cache          `yaml:"cache"`
-	Broker         `yaml:"broker"`
+	Env             string   `yaml:"env" env:"ENV" end-default:"local"`
+	BotToken        string   `yaml:"bottoken" env-required:"true"`
+	OpenWeatherKey  string   `yaml:"openweatherkey" env-required:"true"`
+	WebhookURL      string   `yaml:"webhookurl"`
+	Port            string   `yaml:"port"`
+	Cities          []string `yaml:"cities" env-default:"Санкт-Петербург,Москва,Коломна,Орск"`
+	InlineCacheTime int      `yaml:"inlinecachetime" env-default:"300"` // seconds
+	Admins          []int64  `yaml:"admins"`                            // user IDs of operators
+	Templates       string   `yaml:"templates"`                         // directory with message templates overriding built-in ones
+	Cache           `yaml:"cache"`
+	Broker          `yaml:"broker"`
+	HuggingFace     `yaml:"huggingface"`
+	HuggingFaceKey  string `yaml:"huggingfacekey"` // deprecated, read into huggingface.key
+	Storage         `yaml:"storage"`
+	RateLimit       `yaml:"ratelimit"`
+	Outbox          `yaml:"outbox"`
+	History         `yaml:"history"`
+	Observations    `yaml:"observations"`
+	API             `yaml:"api"`
+	OpenWeather     `yaml:"openweather"`
 }
 
 type Cache struct {
@@ -24,6 +41,8 @@ type Cache struct {
 	Password string `yaml:"password"`
 	DB       int    `yaml:"db"`
 	TTL      int    `yaml:"ttl" env-required:"true"`
+	// Forecast is updated by provider every 3 hours
+	ForecastTTL int `yaml:"forecastttl" env-default:"30"` // minutes
 }
 
 type Broker struct {
@@ -32,27 +51,177 @@ type Broker struct {
 	Timeout int      `yaml:"timeout"`
 }
 
+type Storage struct {
+	Path string `yaml:"path" env-default:"storage/weatherbot.db"`
+}
+
+// Token buckets of updates and weather provider calls
+type RateLimit struct {
+	Rate          float64 `yaml:"rate" env-default:"1"` // updates per second of user
+	Burst         int     `yaml:"burst" env-default:"5"`
+	ProviderRate  float64 `yaml:"providerrate" env-default:"0.2"` // provider calls per second of user
+	ProviderBurst int     `yaml:"providerburst" env-default:"10"`
+	GlobalRate    float64 `yaml:"globalrate" env-default:"5"` // provider calls per second of all users
+	GlobalBurst   int     `yaml:"globalburst" env-default:"20"`
+	DailyBudget   int     `yaml:"dailybudget" env-default:"900"` // provider calls per day, 0 is unlimited
+	BanTime       int     `yaml:"bantime" env-default:"60"`      // minutes
+}
+
+// Pacing of bulk messages: broadcasts, subscriptions, alerts
+type Outbox struct {
+	Rate     float64 `yaml:"rate" env-default:"25"`    // messages per second of all chats
+	ChatRate float64 `yaml:"chatrate" env-default:"1"` // messages per second of chat
+	Attempts int     `yaml:"attempts" env-default:"5"` // delivery attempts before message is dropped
+}
+
+// Archive of observed weather
+type History struct {
+	URL     string `yaml:"url" env-default:"https://archive-api.open-meteo.com/v1/archive"`
+	Timeout int    `yaml:"timeout" env-default:"10"` // seconds
+}
+
+// Retention of recorded weather, older snapshots are downsampled to hourly and daily means
+type Observations struct {
+	RawDays    int `yaml:"rawdays" env-default:"2"`
+	HourlyDays int `yaml:"hourlydays" env-default:"30"`
+	DailyDays  int `yaml:"dailydays" env-default:"365"`
+}
+
+// HTTP API on Port, disabled if there are no keys
+type API struct {
+	Keys           []string `yaml:"keys"`
+	ForecastMaxAge int      `yaml:"forecastmaxage" env-default:"600"` // seconds
+}
+
+// Offline mode of OpenWeather client: record captures responses to Fixtures, replay serves them
+type OpenWeather struct {
+	Mode     string `yaml:"mode"`     // empty is live, record or replay
+	Fixtures string `yaml:"fixtures"` // required for record and replay
+}
+
+// Illustrations are disabled if key is empty
+type HuggingFace struct {
+	Key     string `yaml:"key"`
+	URL     string `yaml:"url" env-default:"https://api-inference.huggingface.co/models"`
+	Model   string `yaml:"model" env-default:"stabilityai/stable-diffusion-xl-base-1.0"`
+	Retries int    `yaml:"retries" env-default:"3"`
+	Timeout int    `yaml:"timeout" env-default:"120"` // seconds
+}
+
 func MustLoad() *Config {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("error loading .env file")
 	}
 
+	cfg, err := Load()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	return cfg
+}
+
+// Read config file from CFG_PATH, used on start and by /reload
+func Load() (*Config, error) {
 	configPath := os.Getenv("CFG_PATH")
 	if configPath == "" {
-		log.Fatal("config path is not set")
+		return nil, errors.New("config path is not set")
 	}
 
 	//check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("config file does not exist: %s", configPath)
+		return nil, fmt.Errorf("config file does not exist: %s", configPath)
 	}
 
 	var cfg Config
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
+	// Key of older configs
+	if cfg.HuggingFace.Key == "" {
+		cfg.HuggingFace.Key = cfg.HuggingFaceKey
+	}
+
+	return &cfg, nil
+}
+
+// Check values which can't be described by tags, all problems are reported at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port != "" {
+		if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("port: invalid value %q", c.Port))
+		}
+	}
+	if c.WebhookURL != "" {
+		if u, err := url.Parse(c.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhookurl: https url is required, got %q", c.WebhookURL))
+		}
+	}
+	if len(c.API.Keys) > 0 && c.Port == "" {
+		errs = append(errs, errors.New("api: keys are set, but port is empty"))
+	}
+	if c.Cache.TTL <= 0 {
+		errs = append(errs, errors.New("cache.ttl: must be positive"))
+	}
+	if c.Cache.ForecastTTL <= 0 {
+		errs = append(errs, errors.New("cache.forecastttl: must be positive"))
+	}
+	if c.RateLimit.Rate <= 0 || c.RateLimit.ProviderRate <= 0 || c.RateLimit.GlobalRate <= 0 {
+		errs = append(errs, errors.New("ratelimit: rates must be positive"))
+	}
+	if c.RateLimit.Burst < 1 || c.RateLimit.ProviderBurst < 1 || c.RateLimit.GlobalBurst < 1 {
+		errs = append(errs, errors.New("ratelimit: bursts must be at least 1"))
+	}
+	if c.Outbox.Rate <= 0 || c.Outbox.ChatRate <= 0 || c.Outbox.Attempts < 1 {
+		errs = append(errs, errors.New("outbox: rates must be positive and attempts at least 1"))
+	}
+	if c.Observations.RawDays <= 0 || c.Observations.HourlyDays < c.Observations.RawDays ||
+		c.Observations.DailyDays < c.Observations.HourlyDays {
+		errs = append(errs, errors.New("observations: retention must grow from raw to hourly to daily"))
+	}
+	switch c.OpenWeather.Mode {
+	case "":
+	case "record", "replay":
+		if c.OpenWeather.Fixtures == "" {
+			errs = append(errs, fmt.Errorf("openweather.fixtures: directory is required in %s mode", c.OpenWeather.Mode))
+		} else if info, err := os.Stat(c.OpenWeather.Fixtures); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("openweather.fixtures: directory %q does not exist", c.OpenWeather.Fixtures))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("openweather.mode: unknown mode %q", c.OpenWeather.Mode))
+	}
+	if c.HuggingFaceKey != "" && c.HuggingFace.Key != c.HuggingFaceKey {
+		errs = append(errs, errors.New("huggingfacekey: deprecated, differs from huggingface.key"))
+	}
+	if c.Templates != "" {
+		if info, err := os.Stat(c.Templates); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("templates: directory %q does not exist", c.Templates))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Config without secrets for logs
+type redacted Config
+
+func (c *Config) LogValue() slog.Value {
+	const hidden = "***"
+
+	r := redacted(*c)
+	for _, secret := range []*string{&r.BotToken, &r.OpenWeatherKey, &r.Cache.Password, &r.HuggingFace.Key, &r.HuggingFaceKey} {
+		if *secret != "" {
+			*secret = hidden
+		}
+	}
+	r.API.Keys = make([]string, len(c.API.Keys))
+	for i := range r.API.Keys {
+		r.API.Keys[i] = hidden
 	}
 
-	return &cfg
+	return slog.AnyValue(r)
 }
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const required = "bottoken: bot-secret\nopenweatherkey: ow-secret\ncache:\n  address: localhost:6379\n  ttl: 10\nbroker:\n  addrs: [localhost:9092]\n"
+
+func load(t *testing.T, config string) *Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(required+config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CFG_PATH", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+func TestHuggingFaceKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		invalid bool
+	}{
+		{name: "nested", config: "huggingface:\n  key: new\n", want: "new"},
+		{name: "deprecated", config: "huggingfacekey: old\n", want: "old"},
+		{name: "same in both", config: "huggingfacekey: key\nhuggingface:\n  key: key\n", want: "key"},
+		{name: "different", config: "huggingfacekey: old\nhuggingface:\n  key: new\n", want: "new", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := load(t, tt.config)
+			if cfg.HuggingFace.Key != tt.want {
+				t.Errorf("key: got %q, want %q", cfg.HuggingFace.Key, tt.want)
+			}
+			if err := cfg.Validate(); (err != nil) != tt.invalid {
+				t.Errorf("validate: got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	cfg := load(t, "huggingface:\n  key: hf-secret\napi:\n  keys: [api-secret]\n")
+	cfg.Cache.Password = "redis-secret"
+
+	var out bytes.Buffer
+	slog.New(slog.NewTextHandler(&out, nil)).Info("config", slog.Any("cfg", cfg))
+
+	for _, secret := range []string{"bot-secret", "ow-secret", "hf-secret", "redis-secret", "api-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("%s is logged: %s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "localhost:6379") {
+		t.Errorf("config is not logged: %s", out.String())
+	}
+	if len(cfg.API.Keys) != 1 || cfg.API.Keys[0] != "api-secret" || cfg.BotToken != "bot-secret" {
+		t.Errorf("config is changed: %+v", cfg)
+	}
+}
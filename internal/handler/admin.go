@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/config"
+	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+	"github.com/m1al04949/weatherbot/internal/router"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+// Commands allowed only in chats from config, every call is written to audit log
+func (h *Handler) adminOnly(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		// By user, not chat: members of a group aren't operators
+		if update.Message == nil || update.Message.From == nil ||
+			!slices.Contains(h.config().Admins, update.Message.From.ID) {
+			return
+		}
+
+		entry := storage.AuditEntry{
+			AdminID: update.Message.From.ID,
+			Action:  update.Message.Command(),
+			Args:    update.Message.CommandArguments(),
+		}
+		h.log.Info("admin action",
+			slog.Int64("admin_id", entry.AdminID),
+			slog.String("action", entry.Action),
+			slog.String("args", entry.Args))
+		if err := h.storage.AddAudit(ctx, entry); err != nil {
+			h.log.Error(err.Error())
+		}
+
+		next(ctx, update)
+	}
+}
+
+// /ban <user_id> [minutes]
+func (h *Handler) commandBan(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+
+	if len(args) == 0 || len(args) > 2 {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Использование: /ban <user_id> [минуты]"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Некорректный user_id"))
+		return
+	}
+
+	minutes := h.config().RateLimit.BanTime
+	if len(args) == 2 {
+		minutes, err = strconv.Atoi(args[1])
+		if err != nil || minutes <= 0 {
+			h.messenger.Send(tgbotapi.NewMessage(chatID, "Некорректное время бана"))
+			return
+		}
+	}
+
+	h.guard.Bans().Ban(userID, time.Duration(minutes)*time.Minute)
+	h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Пользователь %d заблокирован на %d мин", userID, minutes)))
+}
+
+// /unban <user_id>
+func (h *Handler) commandUnban(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	userID, err := strconv.ParseInt(strings.TrimSpace(update.Message.CommandArguments()), 10, 64)
+	if err != nil {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Использование: /unban <user_id>"))
+		return
+	}
+
+	if !h.guard.Bans().Unban(userID) {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Пользователь %d не заблокирован", userID)))
+		return
+	}
+	h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Пользователь %d разблокирован", userID)))
+}
+
+// /bans
+func (h *Handler) commandBans(ctx context.Context, update tgbotapi.Update) {
+	bans := h.guard.Bans().List()
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Запросов к сервису погоды сегодня: %d из %d\n\n",
+		h.guard.Budget().Spent(), h.guard.Budget().Limit()))
+
+	if len(bans) == 0 {
+		text.WriteString("Заблокированных пользователей нет")
+	} else {
+		text.WriteString("Заблокированы:\n")
+		for userID, until := range bans {
+			text.WriteString(fmt.Sprintf("%d до %s\n", userID, until.Format("02.01 15:04")))
+		}
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text.String()))
+}
+
+// /stats
+func (h *Handler) commandStats(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	users, err := h.storage.CountUsers(ctx)
+	if err != nil {
+		h.log.Error(err.Error())
+	}
+
+	var requests int64
+	metrics.Updates.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			requests += v.Value()
+		}
+	})
+
+	hits, misses := metrics.CacheHits.Value(), metrics.CacheMisses.Value()
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses) * 100
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Пользователей: %d\n", users))
+	text.WriteString(fmt.Sprintf("Запросов с запуска: %d\n", requests))
+	text.WriteString(fmt.Sprintf("Попаданий в кэш: %.0f%% (%d из %d)\n", ratio, hits, hits+misses))
+	text.WriteString(fmt.Sprintf("Запросов к сервису погоды сегодня: %d из %d\n",
+		h.guard.Budget().Spent(), h.guard.Budget().Limit()))
+	text.WriteString(fmt.Sprintf("Отклонено лимитом: %d\n", metrics.RateLimited.Value()))
+	text.WriteString(fmt.Sprintf("Ошибок обработчиков: %d", metrics.Panics.Value()))
+
+	h.messenger.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
+
+// /cache flush|warm <city>
+func (h *Handler) commandCache(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	action, city, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	city = strings.TrimSpace(city)
+	if city == "" || (action != "flush" && action != "warm") {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Использование: /cache flush|warm <город>"))
+		return
+	}
+
+	if action == "flush" {
+		deleted, err := h.cache.DeleteWeather(ctx, city)
+		if err != nil {
+			h.log.Error(err.Error())
+			h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось очистить кэш"))
+			return
+		}
+		if !deleted {
+			h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("В кэше нет погоды для %s", city)))
+			return
+		}
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Кэш для %s очищен", city)))
+		return
+	}
+
+	// Operator requests are counted but not limited
+	_, err := h.weather.Refresh(ctx, weatherservice.Query{City: city, Allow: func(calls int) error {
+		h.guard.Budget().Add(calls)
+		return nil
+	}})
+	switch {
+	case errors.Is(err, weatherservice.ErrNotFound):
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Такой населенный пункт не найден"))
+		return
+	case err != nil:
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Погода в населенном пункте %s не определена", city)))
+		return
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Погода для %s добавлена в кэш", city)))
+}
+
+// /broadcast <text>, delivered to all users through outbound queue
+func (h *Handler) commandBroadcast(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	text := strings.TrimSpace(update.Message.CommandArguments())
+	if text == "" {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Использование: /broadcast <текст>"))
+		return
+	}
+
+	users, err := h.storage.UserIDs(ctx)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список пользователей"))
+		return
+	}
+
+	var queued int
+	for _, userID := range users {
+		if err := h.outbox.Enqueue(ctx, userID, text); err != nil {
+			h.log.Error(err.Error())
+			continue
+		}
+		queued++
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Рассылка поставлена в очередь, получателей: %d", queued)))
+}
+
+// /reload, limits, tokens and cache settings are applied after restart
+func (h *Handler) commandReload(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	cfg, err := config.Load()
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Конфигурация не загружена: %s", err.Error())))
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Конфигурация не применена: %s", err.Error())))
+		return
+	}
+	templates, err := f.New(cfg.Templates)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Шаблоны не загружены: %s", err.Error())))
+		return
+	}
+	h.cfg.Store(cfg)
+	h.templates.Store(templates)
+
+	h.log.Info("config reloaded")
+	h.messenger.Send(tgbotapi.NewMessage(chatID,
+		"Конфигурация перечитана: администраторы, города, шаблоны и время бана обновлены. "+
+			"Лимиты, токены и настройки кэша применяются после перезапуска"))
+}
+
+// /accuracy
+func (h *Handler) commandAccuracy(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	stats, err := h.accuracy.Stats(ctx)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось получить статистику"))
+		return
+	}
+	if len(stats) == 0 {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Прогнозы еще не сверены с наблюдениями"))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("Точность прогноза температуры (°C) и ветра (м/с):\n")
+	for _, st := range stats {
+		text.WriteString(fmt.Sprintf("%s, до %d ч: MAE %.1f, RMSE %.1f, смещение %+.1f, ветер MAE %.1f (%d)\n",
+			st.Provider, st.LeadHours, st.TempMAE, st.TempRMSE, st.TempBias, st.SpeedMAE, st.Count))
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/export"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+// /export ics|csv
+func (h *Handler) commandExport(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyToMessageID = message.MessageID
+		h.messenger.Send(msg)
+	}
+
+	format := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	if format != "ics" && format != "csv" {
+		reply("Использование: /export ics|csv")
+		return
+	}
+
+	location, ok := h.weather.Location(ctx, message.Chat.ID)
+	if !ok {
+		reply("Сначала выберите населенный пункт")
+		return
+	}
+	result, err := h.weather.Forecast(ctx, weatherservice.Query{Location: &location, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return
+	}
+	if err != nil {
+		h.log.Error(err.Error())
+		reply(fmt.Sprintf("Прогноз погоды в населенном пункте %s не определен", location.Name))
+		return
+	}
+
+	var file tgbotapi.FileBytes
+	switch format {
+	case "ics":
+		file = tgbotapi.FileBytes{
+			Name:  "forecast.ics",
+			Bytes: export.ICS(location.Name, forecast.Daily(result.Items), time.Now()),
+		}
+	case "csv":
+		data, err := export.CSV(result.Items)
+		if err != nil {
+			h.log.Error(err.Error())
+			reply("Не удалось сформировать файл, попробуйте позже")
+			return
+		}
+		file = tgbotapi.FileBytes{Name: "forecast.csv", Bytes: data}
+	}
+
+	document := tgbotapi.NewDocument(message.Chat.ID, file)
+	document.Caption = fmt.Sprintf("Прогноз погоды: %s", location.Name)
+	document.ReplyToMessageID = message.MessageID
+	h.messenger.Send(document)
+}
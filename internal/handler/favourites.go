@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const (
+	favouriteButton = "★ В избранное"
+	maxFavourites   = 10
+)
+
+// /fav add|remove|list message handler
+func (h *Handler) messageFavourites(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	action, city, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	city = strings.TrimSpace(city)
+
+	switch {
+	case action == "add" && city != "":
+		h.addFavourite(ctx, update, city)
+	case action == "remove" && city != "":
+		removed, err := h.removeFavourite(ctx, userID, city)
+		if err != nil {
+			h.log.Error(err.Error())
+			h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить избранное, попробуйте позже"))
+			return
+		}
+		if !removed {
+			h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s нет в избранном", city)))
+			return
+		}
+		h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s удален из избранного", city)))
+	case action == "list" || action == "":
+		cities, err := h.storage.Favourites(ctx, userID)
+		if err != nil {
+			h.log.Error(err.Error())
+			h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось получить избранное, попробуйте позже"))
+			return
+		}
+		if len(cities) == 0 {
+			h.messenger.Send(tgbotapi.NewMessage(chatID, "В избранном пока ничего нет"))
+			return
+		}
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Избранное:\n"+strings.Join(cities, "\n")))
+	default:
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Использование: /fav add <город>, /fav remove <город>, /fav list"))
+	}
+}
+
+// "★ В избранное" button handler
+func (h *Handler) messageAddFavourite(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.weather.Location(ctx, update.Message.Chat.ID)
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Сначала выберите населенный пункт"))
+		return
+	}
+
+	h.addFavourite(ctx, update, location.Name)
+}
+
+func (h *Handler) addFavourite(ctx context.Context, update tgbotapi.Update, city string) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	cities, err := h.storage.Favourites(ctx, userID)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить избранное, попробуйте позже"))
+		return
+	}
+	for _, favourite := range cities {
+		if strings.EqualFold(favourite, city) {
+			h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s уже в избранном", favourite)))
+			return
+		}
+	}
+	if len(cities) >= maxFavourites {
+		h.messenger.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("В избранном не больше %d населенных пунктов", maxFavourites)))
+		return
+	}
+
+	// Check city exists
+	_, err = h.weather.Locate(ctx, weatherservice.Query{City: city, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return
+	}
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Такой населенный пункт не найден"))
+		return
+	}
+
+	if err := h.storage.AddFavourite(ctx, userID, city); err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить избранное, попробуйте позже"))
+		return
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s добавлен в избранное", city)))
+}
+
+// Remove favourite regardless of case of the name
+func (h *Handler) removeFavourite(ctx context.Context, userID int64, city string) (bool, error) {
+	cities, err := h.storage.Favourites(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, favourite := range cities {
+		if strings.EqualFold(favourite, city) {
+			return h.storage.RemoveFavourite(ctx, userID, favourite)
+		}
+	}
+
+	return false, nil
+}
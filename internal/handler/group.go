@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const groupHelp = "Узнать погоду: /weather <город> или ответьте на мое сообщение названием населенного пункта.\n" +
+	"Прогноз: /forecast, по часам: /hourly.\n" +
+	"Администратор группы может задать город по умолчанию: /setlocation <город>"
+
+// /weather <city>, group default location without city
+func (h *Handler) commandWeather(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+
+	city := strings.TrimSpace(message.CommandArguments())
+	if city != "" {
+		h.messageWeather(ctx, update, city)
+		return
+	}
+
+	if !isGroup(message.Chat) {
+		h.messageOther(message.Chat.ID)
+		return
+	}
+
+	settings, err := h.storage.ChatSettings(ctx, message.Chat.ID)
+	if err != nil {
+		h.log.Error(err.Error())
+	}
+	if err != nil || settings.City == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Укажите населенный пункт: /weather <город>")
+		msg.ReplyToMessageID = message.MessageID
+		h.messenger.Send(msg)
+		return
+	}
+
+	h.messageWeather(ctx, update, settings.City)
+}
+
+// /setlocation <city>, group admins only
+func (h *Handler) messageSetLocation(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyToMessageID = message.MessageID
+		h.messenger.Send(msg)
+	}
+
+	if !isGroup(message.Chat) {
+		reply("Команда доступна только в группах")
+		return
+	}
+
+	member, err := h.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+			ChatID: message.Chat.ID,
+			UserID: message.From.ID,
+		},
+	})
+	if err != nil {
+		h.log.Error(err.Error())
+		reply("Не удалось проверить права, попробуйте позже")
+		return
+	}
+	if !member.IsAdministrator() && !member.IsCreator() {
+		reply("Город группы может задать только администратор")
+		return
+	}
+
+	city := strings.TrimSpace(message.CommandArguments())
+	if city == "" {
+		reply("Укажите населенный пункт: /setlocation <город>")
+		return
+	}
+
+	location, err := h.weather.Locate(ctx, weatherservice.Query{City: city, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return
+	}
+	if err != nil {
+		h.log.Error(err.Error())
+		reply("Такой населенный пункт не найден")
+		return
+	}
+
+	settings, err := h.storage.ChatSettings(ctx, message.Chat.ID)
+	if err != nil {
+		h.log.Error(err.Error())
+		reply("Не удалось сохранить настройки, попробуйте позже")
+		return
+	}
+	settings.City = city
+	settings.Lat = location.Lat
+	settings.Lon = location.Lon
+	if err := h.storage.SaveChatSettings(ctx, *settings); err != nil {
+		h.log.Error(err.Error())
+		reply("Не удалось сохранить настройки, попробуйте позже")
+		return
+	}
+
+	h.weather.SetLocation(message.Chat.ID, location)
+	reply(fmt.Sprintf("Город группы: %s", city))
+}
+
+// Text of replies to the bot and messages starting with @botname
+func (h *Handler) addressedText(message *tgbotapi.Message) (string, bool) {
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil &&
+		message.ReplyToMessage.From.ID == h.bot.Self.ID {
+		text := strings.TrimSpace(message.Text)
+		return text, text != ""
+	}
+
+	mention := "@" + h.bot.Self.UserName
+	if len(message.Text) > len(mention) && strings.EqualFold(message.Text[:len(mention)], mention) {
+		text := strings.TrimSpace(message.Text[len(mention):])
+		return text, text != ""
+	}
+
+	return "", false
+}
+
+func isGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
@@ -2,49 +2,131 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/m1al04949/weatherbot/internal/cache/redis"
 	"github.com/m1al04949/weatherbot/internal/clients/huggingface"
-	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/chart"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
 	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/messenger"
 	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/outbox"
+	"github.com/m1al04949/weatherbot/internal/repositories/accuracyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/historyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/observationrepository"
+	"github.com/m1al04949/weatherbot/internal/router"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage"
 )
 
+// Telegram limit of photo caption length
+const captionLimit = 1024
+
+const renderFailedText = "Не удалось сформировать сообщение, попробуйте позже"
+
 type Handler struct {
-	log      *slog.Logger
-	bot      *tgbotapi.BotAPI
-	owClient *openweather.OpenWeatherClient
-	hfClient *huggingface.HuggingFaceClient
-	cache    *redis.WeatherCache
+	cfg          atomic.Pointer[config.Config] // replaced by /reload
+	templates    atomic.Pointer[f.Templates]   // replaced by /reload
+	log          *slog.Logger
+	bot          *tgbotapi.BotAPI
+	messenger    *messenger.Messenger
+	hfClient     *huggingface.HuggingFaceClient
+	cache        *redis.WeatherCache
+	weather      *weatherservice.WeatherService
+	storage      storage.Repository
+	guard        *ratelimit.Guard
+	outbox       *outbox.Outbox
+	history      *historyrepository.HistoryRepository
+	observations *observationrepository.ObservationRepository
+	accuracy     *accuracyrepository.AccuracyRepository
+	router       *router.Router
+
+	illustrations chan struct{}  // semaphore of generations
+	background    sync.WaitGroup // slow replies out of update loop
+
+	inlineMu sync.Mutex
+	inline   map[string]inlineAnswer // by lower-cased query
 }
 
-var currentLocation models.CordinatesResponse
-
 // Init handler
 func New(
+	cfg *config.Config,
 	log *slog.Logger, bot *tgbotapi.BotAPI,
-	owClient *openweather.OpenWeatherClient,
 	hfClient *huggingface.HuggingFaceClient,
 	cache *redis.WeatherCache,
+	weather *weatherservice.WeatherService,
+	storage storage.Repository,
+	templates *f.Templates,
+	guard *ratelimit.Guard,
+	outbox *outbox.Outbox,
+	history *historyrepository.HistoryRepository,
+	observations *observationrepository.ObservationRepository,
+	accuracy *accuracyrepository.AccuracyRepository,
 ) *Handler {
-	return &Handler{
-		log:      log,
-		bot:      bot,
-		owClient: owClient,
-		hfClient: hfClient,
-		cache:    cache,
+	h := &Handler{
+		log:          log,
+		bot:          bot,
+		messenger:    messenger.New(bot, log),
+		hfClient:     hfClient,
+		cache:        cache,
+		weather:      weather,
+		storage:      storage,
+		guard:        guard,
+		outbox:       outbox,
+		history:      history,
+		observations: observations,
+		accuracy:     accuracy,
+
+		illustrations: make(chan struct{}, illustrationWorkers),
+		inline:        make(map[string]inlineAnswer),
 	}
+	h.cfg.Store(cfg)
+	h.templates.Store(templates)
+	h.router = h.routes()
+
+	return h
+}
+
+// Current config
+func (h *Handler) config() *config.Config {
+	return h.cfg.Load()
+}
+
+// Render message template, errors are logged
+func (h *Handler) render(name string, data any) (string, bool) {
+	text, err := h.templates.Load().Render(name, data)
+	if err != nil {
+		h.log.Error(err.Error())
+		return "", false
+	}
+
+	return text, true
 }
 
 func (h *Handler) Start(ctx context.Context) {
+	// Delayed replies are dropped after background ones are sent
+	defer h.messenger.Close()
+	defer h.background.Wait()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
+	// Publish command list
+	if _, err := h.messenger.Request(tgbotapi.NewSetMyCommands(h.router.BotCommands()...)); err != nil {
+		h.log.Error(fmt.Sprintf("failed to set commands: %s", err.Error()))
+	}
+
 	updates := h.bot.GetUpdatesChan(u)
 
 	// Check updates
@@ -53,104 +135,99 @@ func (h *Handler) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case update := <-updates:
-			h.handlerUpdate(ctx, update)
+			h.router.Handle(ctx, update)
 		}
 	}
 }
 
-// Processing new updates
-func (h *Handler) handlerUpdate(ctx context.Context, update tgbotapi.Update) {
-	if update.Message == nil {
-		return
-	}
-
-	// If we got a message
-	h.log.Info("New message",
-		slog.String("username", update.Message.From.UserName),
-		slog.String("message", update.Message.Text))
-
-	// /start
-	if update.Message.Text == "/start" || update.Message.Text == "Назад" {
-		h.messageStart(update.Message.Chat.ID)
-		return
-	}
-
-	// Get Handle Mode
-	if update.Message.Text == "Ввести вручную" {
-		h.messageOther(update.Message.Chat.ID)
-		return
-	}
-
-	// Get Forecast
-	if update.Message.Text == "Прогноз" {
-		h.messageForecast(update)
+// current weather message handler
+func (h *Handler) messageWeather(ctx context.Context, update tgbotapi.Update, city string) {
+	current, err := h.weather.Current(ctx, weatherservice.Query{City: city, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
 		return
 	}
-
-	// Get current weather
-	var (
-		weather       *models.Weather
-		text          strings.Builder
-		replyKeyboard tgbotapi.ReplyKeyboardMarkup
-	)
-	// From cache
-	cacheWeather, err := h.cache.GetWeather(ctx, update.Message.Text)
 	if err != nil {
 		h.log.Error(err.Error())
-		// Request current weather
-		weather, err = h.messageCurrentWeather(&text, update)
-		if err != nil {
-			h.log.Error(err.Error())
-			replyKeyboard = tgbotapi.NewReplyKeyboard(
+		text := fmt.Sprintf("Погода в населенном пункте %s не определена", city)
+		if errors.Is(err, weatherservice.ErrNotFound) {
+			text = "Такой населенный пункт не найден"
+		}
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.ReplyToMessageID = update.Message.MessageID
+		// Reply buttons don't work in groups
+		if !isGroup(update.Message.Chat) {
+			msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
 				tgbotapi.NewKeyboardButtonRow(
 					tgbotapi.NewKeyboardButton("Назад"),
 				),
 			)
 		}
+		h.messenger.Send(msg)
+		return
 	}
-	if cacheWeather != nil {
-		currentLocation.Name = cacheWeather.City
-		currentLocation.Lat = cacheWeather.Lat
-		currentLocation.Lon = cacheWeather.Lon
-		weather = &cacheWeather.Weather
-		h.log.Info("getting weather from cache")
+
+	h.weather.SetLocation(update.Message.Chat.ID, current.Location)
+	h.sendWeather(update, current.Location.Name, current.Weather)
+}
+
+// Reply with current weather and actions
+func (h *Handler) sendWeather(update tgbotapi.Update, name string, weather models.Weather) {
+	text, ok := h.render(f.TemplateWeather, f.Current{Name: name, Weather: weather})
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, renderFailedText))
+		return
+	}
+
+	buttons := tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("Прогноз"),
+		tgbotapi.NewKeyboardButton("По часам"),
+	)
+	// Optional illustrations
+	if h.hfClient != nil {
+		buttons = append(buttons, tgbotapi.NewKeyboardButton("Иллюстрация"))
 	}
-	if weather != nil {
-		text.WriteString(fmt.Sprintf("Прогноз погоды в населенном пункте %s. \n \n", currentLocation.Name))
-		text.WriteString(f.FormatWeatherMessage(*weather))
-		replyKeyboard = tgbotapi.NewReplyKeyboard(
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = update.Message.MessageID
+	// Reply buttons don't work in groups
+	if !isGroup(update.Message.Chat) {
+		msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+			buttons,
 			tgbotapi.NewKeyboardButtonRow(
-				tgbotapi.NewKeyboardButton("Прогноз"),
+				tgbotapi.NewKeyboardButton(favouriteButton),
+				tgbotapi.NewKeyboardButton(historyButton),
 				tgbotapi.NewKeyboardButton("Назад"),
 			),
 		)
 	}
-
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text.String())
-	msg.ReplyToMessageID = update.Message.MessageID
-	msg.ReplyMarkup = replyKeyboard
-	h.bot.Send(msg)
+	h.messenger.Send(msg)
 }
 
 // /start message
-func (h *Handler) messageStart(id int64) {
-	replyKeyboard := tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Санкт-Петербург"),
-			tgbotapi.NewKeyboardButton("Москва"),
-		),
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Коломна"),
-			tgbotapi.NewKeyboardButton("Орск"),
-		),
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Ввести вручную"),
-		),
-	)
+func (h *Handler) messageStart(ctx context.Context, chatID, userID int64) {
+	// User's favourites or default cities
+	cities, err := h.storage.Favourites(ctx, userID)
+	if err != nil {
+		h.log.Error(err.Error())
+	}
+	if len(cities) == 0 {
+		cities = h.config().Cities
+	}
 
-	msg := tgbotapi.NewMessage(id, "Узнать погоду в населенном пункте")
-	msg.ReplyMarkup = replyKeyboard
-	h.bot.Send(msg)
+	var rows [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(cities); i += 2 {
+		row := tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(cities[i]))
+		if i+1 < len(cities) {
+			row = append(row, tgbotapi.NewKeyboardButton(cities[i+1]))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Ввести вручную")))
+
+	msg := tgbotapi.NewMessage(chatID, "Узнать погоду в населенном пункте")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(rows...)
+	h.messenger.Send(msg)
 }
 
 // other message
@@ -158,119 +235,107 @@ func (h *Handler) messageOther(id int64) {
 
 	msg := tgbotapi.NewMessage(id, "Введите имя населенного пункта")
 	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
-	h.bot.Send(msg)
+	h.messenger.Send(msg)
 }
 
-// current weather message
-func (h *Handler) messageCurrentWeather(text *strings.Builder, update tgbotapi.Update) (*models.Weather, error) {
-	cord, err := h.owClient.Coordinates(update.Message.Text)
-	if err != nil {
-		text.WriteString("Такой населенный пункт не найден")
-		return nil, err
+// Current weather of location from cache or provider, user gets a reply on failure
+func (h *Handler) locationWeather(
+	ctx context.Context, update tgbotapi.Update, location models.CordinatesResponse,
+) (*models.Weather, bool) {
+	current, err := h.weather.Current(ctx, weatherservice.Query{Location: &location, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return nil, false
 	}
-
-	weather, err := h.owClient.CurrentWeather(cord.Lat, cord.Lon)
 	if err != nil {
-		text.WriteString(fmt.Sprintf("Погода в населенном пункте %s не определена", update.Message.Text))
-		return nil, err
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID,
+			fmt.Sprintf("Погода в населенном пункте %s не определена", location.Name)))
+		return nil, false
 	}
 
-	currentLocation.Name = update.Message.Text
-	currentLocation.Lat = cord.Lat
-	currentLocation.Lon = cord.Lon
-
-	return weather, nil
+	return &current.Weather, true
 }
 
 // forecast message handler
-func (h *Handler) messageForecast(update tgbotapi.Update) {
-
-	var text strings.Builder
-
-	todayDate := time.Now().Format(f.DateFormat)
-	targetHour := 13 // on 13:00 every next day
-	replyKeyboard := tgbotapi.NewReplyKeyboard(
+func (h *Handler) messageForecast(ctx context.Context, update tgbotapi.Update) {
+	var replyKeyboard interface{} = tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("По часам"),
 			tgbotapi.NewKeyboardButton("Назад"),
 		),
 	)
+	// Reply buttons don't work in groups
+	if isGroup(update.Message.Chat) {
+		replyKeyboard = nil
+	}
 
 	// Get forecast
-	forecast, err := h.owClient.ForecastWeather(currentLocation.Lat, currentLocation.Lon)
+	location, ok := h.weather.Location(ctx, update.Message.Chat.ID)
+	if !ok {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Сначала выберите населенный пункт")
+		msg.ReplyToMessageID = update.Message.MessageID
+		h.messenger.Send(msg)
+		return
+	}
+	result, err := h.weather.Forecast(ctx, weatherservice.Query{Location: &location, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return
+	}
 	if err != nil {
 		h.log.Error(err.Error())
 		msg := tgbotapi.NewMessage(
 			update.Message.Chat.ID,
-			fmt.Sprintf("Прогноз погоды в населенном пункте %s не определен", update.Message.Text))
+			fmt.Sprintf("Прогноз погоды в населенном пункте %s не определен", location.Name))
 		msg.ReplyToMessageID = update.Message.MessageID
 		msg.ReplyMarkup = replyKeyboard
-		h.bot.Send(msg)
+		h.messenger.Send(msg)
+		return
+	}
+	weather := result.Items
+
+	// Filter forecast in the location's local time
+	todayForecast, nextDaysForecast := forecast.Split(weather, time.Now())
+
+	text, ok := h.render(f.TemplateForecast, f.Forecast{
+		Name:  location.Name,
+		Today: todayForecast,
+		Days:  nextDaysForecast,
+	})
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, renderFailedText))
 		return
 	}
 
-	// Filter forecast
-	var todayForecast, nextDaysForecast []models.Weather
-	processedDays := make(map[string]bool)
-
-	for _, item := range *forecast {
-		itemTime, err := time.Parse(f.DateTimeFormat, item.Date)
-		if err != nil {
-			h.log.Error(err.Error())
-			continue
-		}
-
-		// Today
-		if itemTime.Format(f.DateFormat) == todayDate {
-			if itemTime.Hour() > time.Now().Hour() {
-				todayForecast = append(todayForecast, item)
-			}
-			continue
-		}
-
-		// Next day on 13:00
-		dateStr := itemTime.Format(f.DateFormat)
-		if !processedDays[dateStr] && itemTime.Hour() >= targetHour-1 && itemTime.Hour() <= targetHour+1 {
-			nextDaysForecast = append(nextDaysForecast, item)
-			processedDays[dateStr] = true
-		}
+	// Render chart, text table is the fallback
+	image, err := chart.Render(weather)
+	if err != nil {
+		h.log.Error(fmt.Sprintf("failed to render chart: %s", err.Error()))
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = replyKeyboard
+		h.messenger.Send(msg)
+		return
 	}
 
-	// Formating New forecast
-	text.WriteString(fmt.Sprintf("Прогноз погоды в населенном пункте %s. \n \n", currentLocation.Name))
+	photo := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{
+		Name:  "weather_forecast.png",
+		Bytes: image,
+	})
+	photo.ParseMode = tgbotapi.ModeHTML
+	photo.ReplyMarkup = replyKeyboard
 
-	// Today
-	text.WriteString("============= Сегодня ============\n")
-	text.WriteString("┌─────────────────────────┐\n")
-	text.WriteString("│ Время   Температура   Погода   Ветер \n")
-	for _, item := range todayForecast {
-		text.WriteString(f.FormatWeatherMessage(item))
-	}
-	text.WriteString("└─────────────────────────┘")
-
-	// Next days
-	if len(nextDaysForecast) > 0 {
-		text.WriteString("\n======== На следующие дни ========\n")
-		text.WriteString("┌─────────────────────────┐\n")
-		text.WriteString("│ День    Температура   Погода    Ветер \n")
-		for _, item := range nextDaysForecast {
-			text.WriteString(f.FormatWeatherMessage(item))
-		}
-		text.WriteString("└─────────────────────────┘")
+	// Table doesn't fit into caption, send it after the chart
+	if utf8.RuneCountInString(text) > captionLimit {
+		photo.Caption = fmt.Sprintf("Прогноз погоды в населенном пункте <b>%s</b> 🌤️", html.EscapeString(location.Name))
+		h.messenger.Send(photo)
+
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = replyKeyboard
+		h.messenger.Send(msg)
+		return
 	}
 
-	// Generate image
-	// image, err := h.hfClient.GenerateWithHuggingFace(text.String())
-	// if err != nil {
-	// 	h.log.Error(fmt.Sprintf("failed to generate image: %s", err.Error()))
-	// }
-	// photo := tgbotapi.FileBytes{
-	// 	Name:  "weather_forecast.png",
-	// 	Bytes: image,
-	// }
-	// msg := tgbotapi.NewPhoto(update.Message.Chat.ID, photo)
-	// msg.Caption = "Прогноз погоды 🌤️"
-
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text.String())
-	msg.ReplyMarkup = replyKeyboard
-	h.bot.Send(msg)
+	photo.Caption = text
+	h.messenger.Send(photo)
 }
@@ -0,0 +1,425 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/clients/huggingface"
+	"github.com/m1al04949/weatherbot/internal/clients/openmeteo"
+	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/lib/telegramtest"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/outbox"
+	"github.com/m1al04949/weatherbot/internal/repositories/accuracyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/historyrepository"
+	"github.com/m1al04949/weatherbot/internal/repositories/observationrepository"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
+)
+
+const (
+	userID  = 42
+	adminID = 7
+	groupID = -100
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// In-memory stand-in of Redis
+type fakeCache struct {
+	mu        sync.Mutex
+	weather   map[string]models.CacheWeather
+	forecasts map[string]models.CacheForecast
+}
+
+func (c *fakeCache) GetWeather(ctx context.Context, city string) (*models.CacheWeather, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	weather, ok := c.weather[city]
+	if !ok {
+		return nil, errors.New("redis: nil")
+	}
+	return &weather, nil
+}
+
+func (c *fakeCache) UpdateWeather(ctx context.Context, weather models.CacheWeather) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.weather[weather.City] = weather
+	return nil
+}
+
+func (c *fakeCache) GetForecast(ctx context.Context, city string) (*models.CacheForecast, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	forecast, ok := c.forecasts[city]
+	if !ok {
+		return nil, errors.New("redis: nil")
+	}
+	return &forecast, nil
+}
+
+func (c *fakeCache) UpdateForecast(ctx context.Context, forecast models.CacheForecast) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.forecasts[forecast.City] = forecast
+	return nil
+}
+
+type transportFunc func(req *http.Request) (*http.Response, error)
+
+func (f transportFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type scenario struct {
+	tg    *telegramtest.Server
+	cache *fakeCache
+	guard *ratelimit.Guard
+	calls atomic.Int32 // of weather provider
+}
+
+// Bot with fake Telegram, OpenWeather replaying fixtures of its client and in-memory cache,
+// zero budget of provider calls is unlimited
+func newScenario(t *testing.T, hfClient *huggingface.HuggingFaceClient, budget int) *scenario {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		Cities:          []string{"Москва", "Казань"},
+		InlineCacheTime: 300,
+		Admins:          []int64{adminID, groupID},
+		Cache:           config.Cache{TTL: 10},
+		RateLimit:       config.RateLimit{Rate: 100, Burst: 100},
+		Outbox:          config.Outbox{Rate: 25, ChatRate: 1, Attempts: 1},
+		Observations:    config.Observations{RawDays: 2, HourlyDays: 30, DailyDays: 365},
+	}
+
+	s := &scenario{
+		tg:    telegramtest.NewServer(t),
+		cache: &fakeCache{weather: map[string]models.CacheWeather{}, forecasts: map[string]models.CacheForecast{}},
+	}
+	bot := s.tg.Bot()
+
+	storage, err := sqlite.New(filepath.Join(t.TempDir(), "weatherbot.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	templates, err := format.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures := openweather.NewFixtures(filepath.Join("..", "clients", "openweather", "testdata"), false, nil)
+	provider := openweather.New("test", transportFunc(func(req *http.Request) (*http.Response, error) {
+		s.calls.Add(1)
+		return fixtures.RoundTrip(req)
+	}))
+
+	observations := observationrepository.New(cfg.Observations, log, storage)
+	accuracy := accuracyrepository.New(log, storage)
+	weather := weatherservice.New(log, provider, s.cache, observations, accuracy, storage)
+	s.guard = ratelimit.NewGuard(
+		ratelimit.New(100, 100), ratelimit.New(100, 100), ratelimit.NewBudget(budget), ratelimit.NewBanList())
+
+	h := New(
+		cfg, log, bot, hfClient, nil, weather, storage, templates, s.guard,
+		outbox.New(cfg.Outbox, log, bot, storage),
+		historyrepository.New(log, openmeteo.New(cfg.History), storage),
+		observations, accuracy,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Start(ctx)
+	}()
+	// Handler stops before updates channel is closed
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	s.tg.Expect("setMyCommands")
+
+	return s
+}
+
+func expectText(t *testing.T, req telegramtest.Request, parts ...string) {
+	t.Helper()
+
+	for _, part := range parts {
+		if !strings.Contains(req.Text(), part) {
+			t.Errorf("%s: text %q doesn't contain %q", req.Method, req.Text(), part)
+		}
+	}
+}
+
+func expectButtons(t *testing.T, req telegramtest.Request, buttons ...string) {
+	t.Helper()
+
+	got := req.Buttons()
+	for _, button := range buttons {
+		if !slices.Contains(got, button) {
+			t.Errorf("%s: buttons %q don't contain %q", req.Method, got, button)
+		}
+	}
+}
+
+func TestWeatherFlow(t *testing.T) {
+	s := newScenario(t, nil, 0)
+
+	s.tg.SendText(userID, "/start")
+	start := s.tg.Expect("sendMessage")
+	if start.ChatID() != userID {
+		t.Errorf("chat: got %d, want %d", start.ChatID(), userID)
+	}
+	expectText(t, start, "Узнать погоду")
+	expectButtons(t, start, "Москва", "Казань", "Ввести вручную")
+
+	s.tg.SendText(userID, "Ввести вручную")
+	expectText(t, s.tg.Expect("sendMessage"), "Введите имя населенного пункта")
+
+	s.tg.SendText(userID, "Москва")
+	current := s.tg.Expect("sendMessage")
+	expectText(t, current, "Москва", "7°C", "небольшой дождь")
+	expectButtons(t, current, "Прогноз", "По часам", favouriteButton, historyButton, "Назад")
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("provider calls: got %d, want geocoding and weather", calls)
+	}
+
+	// Repeated request is served from cache
+	s.tg.SendText(userID, "Москва")
+	expectText(t, s.tg.Expect("sendMessage"), "7°C")
+	if calls := s.calls.Load(); calls != 2 {
+		t.Errorf("provider calls: got %d, want 2", calls)
+	}
+
+	s.tg.SendText(userID, "Прогноз")
+	photo := s.tg.Expect("sendPhoto")
+	expectText(t, photo, "Москва")
+	expectButtons(t, photo, "По часам", "Назад")
+	if !bytes.HasPrefix(photo.Files["photo"], pngHeader) {
+		t.Errorf("chart is not png: %d bytes", len(photo.Files["photo"]))
+	}
+
+	s.tg.SendText(userID, "По часам")
+	hourly := s.tg.Expect("sendMessage")
+	expectButtons(t, hourly, "день 1/2", "▶")
+
+	data, ok := hourly.Callback("▶")
+	if !ok {
+		t.Fatalf("no next page button: %q", hourly.Buttons())
+	}
+	s.tg.Press(userID, hourly, data)
+	s.tg.Expect("answerCallbackQuery")
+	edit := s.tg.Expect("editMessageText")
+	if edit.MessageID != hourly.MessageID {
+		t.Errorf("edited message: got %d, want %d", edit.MessageID, hourly.MessageID)
+	}
+	expectButtons(t, edit, "◀", "день 2/2")
+
+	// Page number only stops the button animation
+	calls := s.calls.Load()
+	data, _ = edit.Callback("день 2/2")
+	s.tg.Press(userID, hourly, data)
+	s.tg.Expect("answerCallbackQuery")
+	s.tg.Silent(100 * time.Millisecond)
+	if s.calls.Load() != calls {
+		t.Errorf("provider calls: got %d, want %d", s.calls.Load(), calls)
+	}
+
+	s.tg.SendText(userID, "Назад")
+	back := s.tg.Expect("sendMessage")
+	expectText(t, back, "Узнать погоду")
+	expectButtons(t, back, "Москва", "Ввести вручную")
+
+	s.tg.Silent(100 * time.Millisecond)
+}
+
+func TestUnknownCity(t *testing.T) {
+	s := newScenario(t, nil, 0)
+
+	s.tg.SendText(userID, "Прогноз")
+	expectText(t, s.tg.Expect("sendMessage"), "Сначала выберите населенный пункт")
+
+	s.tg.SendText(userID, "Нигде")
+	reply := s.tg.Expect("sendMessage")
+	expectText(t, reply, "Такой населенный пункт не найден")
+	expectButtons(t, reply, "Назад")
+
+	if len(s.cache.weather) != 0 {
+		t.Errorf("nothing must be cached: %v", s.cache.weather)
+	}
+}
+
+func TestIllustrationDoesNotBlockUpdates(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write(pngHeader)
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	s := newScenario(t, huggingface.New(config.HuggingFace{Key: "test", URL: server.URL, Model: "model", Timeout: 10}), 0)
+
+	s.tg.SendText(userID, "Москва")
+	expectButtons(t, s.tg.Expect("sendMessage"), "Иллюстрация")
+
+	s.tg.SendText(userID, "Иллюстрация")
+	s.tg.Expect("sendChatAction")
+
+	// Bot answers while the image is being generated
+	s.tg.SendText(userID, "/start")
+	expectText(t, s.tg.Expect("sendMessage"), "Узнать погоду")
+
+	close(release)
+	photo := s.tg.Expect("sendPhoto")
+	expectText(t, photo, "Москва", "🎨")
+	if !bytes.Equal(photo.Files["photo"], pngHeader) {
+		t.Errorf("photo: got %q", photo.Files["photo"])
+	}
+}
+
+func TestInline(t *testing.T) {
+	s := newScenario(t, nil, 0)
+
+	s.tg.Inline(userID, "Москва")
+	answer := s.tg.Expect("answerInlineQuery")
+	if results := answer.Results(); len(results) != 1 || !strings.HasPrefix(results[0], "Москва") ||
+		!strings.Contains(results[0], "сейчас") {
+		t.Errorf("results: got %q, want current weather", results)
+	}
+	// Geocoding and weather, forecast isn't fetched for previews
+	if calls, spent := s.calls.Load(), s.guard.Budget().Spent(); calls != 2 || spent != 2 {
+		t.Errorf("provider calls %d, spent %d, want 2 and 2", calls, spent)
+	}
+
+	// Repeated query is answered from cache for free
+	s.tg.Inline(userID, "москва ")
+	if results := s.tg.Expect("answerInlineQuery").Results(); len(results) != 1 {
+		t.Errorf("cached results: got %q", results)
+	}
+	if calls, spent := s.calls.Load(), s.guard.Budget().Spent(); calls != 2 || spent != 2 {
+		t.Errorf("provider calls %d, spent %d, want 2 and 2", calls, spent)
+	}
+
+	// Short query costs nothing
+	s.tg.Inline(userID, "М")
+	if results := s.tg.Expect("answerInlineQuery").Results(); len(results) != 0 {
+		t.Errorf("results of short query: got %q", results)
+	}
+}
+
+func TestInlineLimited(t *testing.T) {
+	s := newScenario(t, nil, 1)
+
+	s.tg.Inline(userID, "Москва")
+	answer := s.tg.Expect("answerInlineQuery")
+	if results := answer.Results(); len(results) != 0 {
+		t.Errorf("results: got %q, want none", results)
+	}
+	if answer.Params.Get("switch_pm_text") == "" || answer.Params.Get("is_personal") != "true" {
+		t.Errorf("limited answer must be explained and not cached: %v", answer.Params)
+	}
+	if calls := s.calls.Load(); calls != 1 {
+		t.Errorf("provider calls: got %d, want 1", calls)
+	}
+
+	// Partial results aren't cached, budget is spent for geocoding now
+	s.tg.Inline(userID, "Москва")
+	if results := s.tg.Expect("answerInlineQuery").Results(); len(results) != 0 {
+		t.Errorf("results: got %q, want none", results)
+	}
+	if calls := s.calls.Load(); calls != 1 {
+		t.Errorf("provider calls: got %d, want 1", calls)
+	}
+}
+
+func TestInlineCap(t *testing.T) {
+	var allowed int
+	allowFunc := capped(func(calls int) error {
+		allowed += calls
+		return nil
+	}, inlineMaxCalls)
+
+	// Geocoding and weather of two candidates, the third one is over the cap
+	for i, want := range []error{nil, nil, nil, errInlineCap} {
+		if err := allowFunc(1); err != want {
+			t.Errorf("call %d: got %v, want %v", i, err, want)
+		}
+	}
+	if allowed != inlineMaxCalls {
+		t.Errorf("allowed calls: got %d, want %d", allowed, inlineMaxCalls)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	s := newScenario(t, nil, 0)
+
+	// Group is listed, but its members aren't operators
+	s.tg.SendGroupText(groupID, userID, "/bans")
+	s.tg.Silent(200 * time.Millisecond)
+
+	s.tg.SendGroupText(groupID, adminID, "/bans")
+	expectText(t, s.tg.Expect("sendMessage"), "Заблокированных пользователей нет")
+
+	s.tg.SendText(adminID, "/bans")
+	expectText(t, s.tg.Expect("sendMessage"), "Заблокированных пользователей нет")
+}
+
+func TestReload(t *testing.T) {
+	s := newScenario(t, nil, 0)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CFG_PATH", path)
+	write := func(config string) {
+		t.Helper()
+		config = "bottoken: token\nopenweatherkey: key\ncache:\n  address: localhost:6379\n  ttl: 10\n" +
+			"broker:\n  addrs: [localhost:9092]\nadmins: [7]\n" + config
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Invalid config isn't applied, Москва is still on the start keyboard
+	write("cities: [Орск]\ntemplates: missing\n")
+	s.tg.SendText(adminID, "/reload")
+	expectText(t, s.tg.Expect("sendMessage"), "Конфигурация не применена", "templates")
+
+	s.tg.SendText(adminID, "/start")
+	expectButtons(t, s.tg.Expect("sendMessage"), "Москва")
+
+	write("cities: [Орск]\n")
+	s.tg.SendText(adminID, "/reload")
+	expectText(t, s.tg.Expect("sendMessage"), "Конфигурация перечитана")
+
+	s.tg.SendText(adminID, "/start")
+	expectButtons(t, s.tg.Expect("sendMessage"), "Орск")
+}
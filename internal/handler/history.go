@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/clients/openmeteo"
+	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const historyButton = "Как было год назад"
+
+// The archive starts in 1940
+var historyStart = time.Date(1940, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// /history <city> <date>
+func (h *Handler) commandHistory(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyToMessageID = message.MessageID
+		h.messenger.Send(msg)
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 2 {
+		reply("Использование: /history <город> <дд.мм.гггг>")
+		return
+	}
+	city := strings.Join(args[:len(args)-1], " ")
+
+	date, err := parseDate(args[len(args)-1])
+	if err != nil {
+		reply("Дата указывается в формате дд.мм.гггг, например 15.03.2024")
+		return
+	}
+
+	// Coordinates and current weather for comparison
+	location, err := h.weather.Locate(ctx, weatherservice.Query{City: city, Allow: h.providerAllowed(update)})
+	if errors.Is(err, weatherservice.ErrRateLimited) {
+		return
+	}
+	if err != nil {
+		h.log.Error(err.Error())
+		reply("Такой населенный пункт не найден")
+		return
+	}
+
+	var today *models.Weather
+	current, err := h.weather.Current(ctx, weatherservice.Query{Location: &location, Allow: h.providerAllowed(update)})
+	switch {
+	case errors.Is(err, weatherservice.ErrRateLimited):
+		return
+	case err != nil:
+		h.log.Error(err.Error())
+	default:
+		today = &current.Weather
+	}
+
+	// Date is a day in the location
+	tz := time.UTC
+	if today != nil {
+		tz = today.Date.Location()
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, tz)
+
+	now := time.Now().In(tz)
+	if !date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tz)) || date.Before(historyStart) {
+		reply(fmt.Sprintf("Укажите прошедшую дату не раньше %s", historyStart.Format("02.01.2006")))
+		return
+	}
+	if !openmeteo.Archived(date, now) {
+		latest := now.AddDate(0, 0, -openmeteo.ArchiveDays)
+		reply(fmt.Sprintf("Архив погоды пополняется с задержкой, укажите дату не позже %s", latest.Format("02.01.2006")))
+		return
+	}
+
+	h.sendHistory(ctx, update, location, date, today)
+}
+
+// "Как было год назад" button handler
+func (h *Handler) messageYearAgo(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.weather.Location(ctx, update.Message.Chat.ID)
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Сначала выберите населенный пункт"))
+		return
+	}
+
+	today, ok := h.locationWeather(ctx, update, location)
+	if !ok {
+		return
+	}
+
+	h.sendHistory(ctx, update, location, today.Date.AddDate(-1, 0, 0), today)
+}
+
+// Observed weather of the day compared with today
+func (h *Handler) sendHistory(
+	ctx context.Context, update tgbotapi.Update,
+	location models.CordinatesResponse, date time.Time, today *models.Weather,
+) {
+	chatID := update.Message.Chat.ID
+
+	day, err := h.history.Day(ctx, location.Lat, location.Lon, date)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Нет данных о погоде в населенном пункте %s за %s", location.Name, date.Format("02.01.2006"))))
+		return
+	}
+
+	text, ok := h.render(f.TemplateHistory, f.History{Name: location.Name, Day: *day, Today: today})
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, renderFailedText))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = update.Message.MessageID
+	h.messenger.Send(msg)
+}
+
+// dd.mm.yyyy or yyyy-mm-dd
+func parseDate(value string) (time.Time, error) {
+	date, err := time.Parse("02.01.2006", value)
+	if err == nil {
+		return date, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
+	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const (
+	hourlyPrefix = "hourly:"
+	noopCallback = "noop" // buttons which only show state, e.g. page number
+)
+
+// ◀ день ▶ buttons handler
+func (h *Handler) callbackHourly(ctx context.Context, update tgbotapi.Update) {
+	query := update.CallbackQuery
+
+	if !h.allowProvider(update, 1) {
+		return
+	}
+
+	// Stop loading animation on the button
+	if _, err := h.messenger.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		h.log.Error(err.Error())
+	}
+
+	if query.Message == nil {
+		return
+	}
+
+	page, err := strconv.Atoi(strings.TrimPrefix(query.Data, hourlyPrefix))
+	if err != nil {
+		h.log.Error(err.Error())
+		return
+	}
+	h.editHourlyForecast(ctx, query.Message.Chat.ID, query.Message.MessageID, page)
+}
+
+// Answer button without action, the message stays as is
+func (h *Handler) callbackNoop(ctx context.Context, update tgbotapi.Update) {
+	if _, err := h.messenger.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, "")); err != nil {
+		h.log.Error(err.Error())
+	}
+}
+
+// hourly forecast message handler
+func (h *Handler) messageHourlyForecast(ctx context.Context, update tgbotapi.Update) {
+	if !h.allowProvider(update, 1) {
+		return
+	}
+
+	text, keyboard, err := h.hourlyForecastPage(ctx, update.Message.Chat.ID, 0)
+	if err != nil {
+		h.log.Error(err.Error())
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.ReplyToMessageID = update.Message.MessageID
+		h.messenger.Send(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	h.messenger.Send(msg)
+}
+
+// Switch page of hourly forecast in place
+func (h *Handler) editHourlyForecast(ctx context.Context, chatID int64, messageID, page int) {
+	text, keyboard, err := h.hourlyForecastPage(ctx, chatID, page)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	h.messenger.Send(edit)
+}
+
+// Build text and buttons for one day of hourly forecast
+func (h *Handler) hourlyForecastPage(ctx context.Context, chatID int64, page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	location, ok := h.weather.Location(ctx, chatID)
+	if !ok {
+		return "Сначала выберите населенный пункт", tgbotapi.InlineKeyboardMarkup{},
+			fmt.Errorf("no location for chat %d", chatID)
+	}
+
+	result, err := h.weather.Forecast(ctx, weatherservice.Query{Location: &location})
+	if err != nil {
+		return fmt.Sprintf("Прогноз погоды в населенном пункте %s не определен", location.Name),
+			tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	days := forecast.GroupByDay(result.Items)
+	if len(days) == 0 {
+		return fmt.Sprintf("Прогноз погоды в населенном пункте %s не определен", location.Name),
+			tgbotapi.InlineKeyboardMarkup{}, fmt.Errorf("empty forecast for %s", location.Name)
+	}
+
+	page = max(0, min(page, len(days)-1))
+
+	text, err := h.templates.Load().Render(f.TemplateHourly, f.Hourly{Name: location.Name, Items: days[page]})
+	if err != nil {
+		return renderFailedText, tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	return text, hourlyKeyboard(days, page), nil
+}
+
+// ◀ день ▶ buttons
+func hourlyKeyboard(days [][]models.Weather, page int) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀", hourlyPrefix+strconv.Itoa(page-1)))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+		fmt.Sprintf("день %d/%d", page+1, len(days)), noopCallback))
+	if page < len(days)-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶", hourlyPrefix+strconv.Itoa(page+1)))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+const (
+	illustrationWorkers = 2               // concurrent generations, others are refused
+	illustrationTimeout = 3 * time.Minute // including retries while model is loading
+)
+
+// illustration of current weather message handler
+func (h *Handler) messageIllustration(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	location, ok := h.weather.Location(ctx, chatID)
+	if !ok {
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Сначала выберите населенный пункт"))
+		return
+	}
+
+	weather, ok := h.locationWeather(ctx, update, location)
+	if !ok {
+		return
+	}
+
+	// Generation takes minutes while the model is loading, other updates don't wait for it
+	select {
+	case h.illustrations <- struct{}{}:
+	default:
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Сейчас рисуется слишком много иллюстраций, попробуйте позже"))
+		return
+	}
+
+	h.messenger.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatUploadPhoto))
+
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		defer func() { <-h.illustrations }()
+
+		h.sendIllustration(ctx, chatID, location.Name, *weather)
+	}()
+}
+
+func (h *Handler) sendIllustration(ctx context.Context, chatID int64, name string, weather models.Weather) {
+	ctx, cancel := context.WithTimeout(ctx, illustrationTimeout)
+	defer cancel()
+
+	image, err := h.hfClient.Illustrate(ctx, weather)
+	if err != nil {
+		h.log.Error(fmt.Sprintf("failed to generate image: %s", err.Error()))
+		h.messenger.Send(tgbotapi.NewMessage(chatID, "Не удалось нарисовать иллюстрацию, попробуйте позже"))
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{
+		Name:  "weather_illustration.png",
+		Bytes: image,
+	})
+	photo.Caption = fmt.Sprintf("%s, %s 🎨", name, weather.Description)
+	h.messenger.Send(photo)
+}
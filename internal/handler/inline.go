@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const (
+	inlineCandidates = 3
+	inlineMinQuery   = 2
+	inlineMaxCalls   = 3 // provider calls per query: geocoding and weather of uncached candidates
+)
+
+// Calls over the cap of inline query, candidates without cached weather are skipped
+var errInlineCap = errors.New("provider calls of inline query are capped")
+
+// Results of inline query kept while Telegram may still ask for them
+type inlineAnswer struct {
+	results []interface{}
+	expires time.Time
+}
+
+// @botname <city> inline query handler
+func (h *Handler) handlerInline(ctx context.Context, update tgbotapi.Update) {
+	query := update.InlineQuery
+	city := strings.TrimSpace(query.Query)
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		CacheTime:     h.config().InlineCacheTime,
+	}
+
+	if utf8.RuneCountInString(city) >= inlineMinQuery {
+		key := strings.ToLower(city)
+		if results, ok := h.inlineCached(key); ok {
+			answer.Results = results
+		} else {
+			var denied error
+			answer.Results = h.inlineResults(ctx, city, h.inlineAllowed(query.From.ID, &denied))
+
+			if denied == nil {
+				h.cacheInline(key, answer.Results)
+			} else if text := inlineLimitedText(denied); text != "" {
+				// Partial results must not be cached by Telegram either
+				answer.CacheTime = 0
+				answer.IsPersonal = true
+				answer.SwitchPMText = text
+				answer.SwitchPMParameter = "limited"
+			}
+		}
+	}
+
+	if _, err := h.messenger.Request(answer); err != nil {
+		h.log.Error(err.Error())
+	}
+}
+
+// Provider check for inline queries, the first denial is kept for the answer
+func (h *Handler) inlineAllowed(userID int64, denied *error) weatherservice.AllowFunc {
+	return func(calls int) error {
+		err := h.guard.Allow(userID, calls)
+		if err != nil && *denied == nil {
+			h.log.Info("provider call denied", slog.Int64("user_id", userID), slog.String("reason", err.Error()))
+			*denied = err
+		}
+		return err
+	}
+}
+
+// Allow func denying calls over limit, the denied ones aren't passed to allowFunc
+func capped(allowFunc weatherservice.AllowFunc, limit int) weatherservice.AllowFunc {
+	var total int
+	return func(calls int) error {
+		if total+calls > limit {
+			return errInlineCap
+		}
+		if err := allowFunc(calls); err != nil {
+			return err
+		}
+		total += calls
+		return nil
+	}
+}
+
+// Button text of inline answer, banned users get nothing
+func inlineLimitedText(err error) string {
+	switch {
+	case errors.Is(err, ratelimit.ErrTooOften):
+		return "Слишком часто, повторите запрос позже"
+	case errors.Is(err, ratelimit.ErrOverloaded):
+		return "Сейчас слишком много запросов"
+	case errors.Is(err, ratelimit.ErrBudgetSpent):
+		return "Лимит запросов на сегодня исчерпан"
+	}
+
+	return ""
+}
+
+func (h *Handler) inlineCached(key string) ([]interface{}, bool) {
+	h.inlineMu.Lock()
+	defer h.inlineMu.Unlock()
+
+	answer, ok := h.inline[key]
+	if !ok || time.Now().After(answer.expires) {
+		return nil, false
+	}
+
+	return answer.results, true
+}
+
+// Keep results for inline cache time, expired ones are dropped
+func (h *Handler) cacheInline(key string, results []interface{}) {
+	ttl := time.Duration(h.config().InlineCacheTime) * time.Second
+	if ttl <= 0 {
+		return
+	}
+
+	h.inlineMu.Lock()
+	defer h.inlineMu.Unlock()
+
+	now := time.Now()
+	for k, answer := range h.inline {
+		if now.After(answer.expires) {
+			delete(h.inline, k)
+		}
+	}
+	h.inline[key] = inlineAnswer{results: results, expires: now.Add(ttl)}
+}
+
+// Current weather article for every geocoding candidate, the query is typed
+// a key at a time, so forecast is left for the bot's chat and provider calls are capped.
+// Every provider call is checked by allowFunc, cached weather is free
+func (h *Handler) inlineResults(ctx context.Context, city string, allowFunc weatherservice.AllowFunc) []interface{} {
+	results := []interface{}{}
+	allowFunc = capped(allowFunc, inlineMaxCalls)
+
+	locations, err := h.weather.Candidates(ctx, city, inlineCandidates, allowFunc)
+	if err != nil {
+		h.log.Error(err.Error())
+		return results
+	}
+
+	for _, location := range locations {
+		name := locationTitle(location)
+
+		// Candidates may have the same name, weather is cached by coordinates
+		result, err := h.weather.Current(ctx, weatherservice.Query{
+			Location: &models.CordinatesResponse{Lat: location.Lat, Lon: location.Lon},
+			Allow:    allowFunc,
+			NoRecord: true,
+		})
+		if err != nil {
+			h.log.Error(err.Error())
+			continue
+		}
+		weather := result.Weather
+
+		text, ok := h.render(f.TemplateWeather, f.Current{Name: name, Weather: weather})
+		if !ok {
+			continue
+		}
+		current := tgbotapi.NewInlineQueryResultArticleHTML(
+			fmt.Sprintf("current:%.4f:%.4f", location.Lat, location.Lon),
+			fmt.Sprintf("%s: сейчас %d°C", name, int(math.Round(weather.Temp))),
+			text,
+		)
+		current.Description = fmt.Sprintf("%s, ветер %d м/с", weather.Description, int(math.Round(weather.Speed)))
+		results = append(results, current)
+	}
+
+	return results
+}
+
+// Russian name with region and country
+func locationTitle(location models.CordinatesResponse) string {
+	name := location.Name
+	if local, ok := location.LocalNames["ru"]; ok {
+		name = local
+	}
+
+	parts := []string{name}
+	if location.State != "" {
+		parts = append(parts, location.State)
+	}
+	if location.Country != "" {
+		parts = append(parts, location.Country)
+	}
+
+	return strings.Join(parts, ", ")
+}
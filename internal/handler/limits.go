@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/router"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const tooOftenText = "Слишком часто 🙏 Подождите немного и повторите запрос"
+
+var errProviderDenied = errors.New("provider call denied")
+
+// Drop updates of banned users
+func (h *Handler) dropBanned(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		if user := update.SentFrom(); user != nil && h.guard.Bans().Banned(user.ID) {
+			h.log.Info("update of banned user dropped", slog.Int64("user_id", user.ID))
+			return
+		}
+
+		next(ctx, update)
+	}
+}
+
+// Provider check for repositories, user has already got a reply when it fails
+func (h *Handler) providerAllowed(update tgbotapi.Update) weatherservice.AllowFunc {
+	return func(calls int) error {
+		if !h.allowProvider(update, calls) {
+			return errProviderDenied
+		}
+		return nil
+	}
+}
+
+// Check limits before weather provider calls, user gets a reply if denied
+func (h *Handler) allowProvider(update tgbotapi.Update, calls int) bool {
+	user := update.SentFrom()
+	if user == nil {
+		return false
+	}
+
+	err := h.guard.Allow(user.ID, calls)
+	if err == nil {
+		return true
+	}
+
+	h.log.Info("provider call denied", slog.Int64("user_id", user.ID), slog.String("reason", err.Error()))
+
+	var text string
+	switch {
+	case errors.Is(err, ratelimit.ErrBanned):
+		return false
+	case errors.Is(err, ratelimit.ErrTooOften):
+		text = tooOftenText
+	case errors.Is(err, ratelimit.ErrOverloaded):
+		text = "Сейчас слишком много запросов, попробуйте через минуту"
+	case errors.Is(err, ratelimit.ErrBudgetSpent):
+		text = fmt.Sprintf("Лимит запросов к сервису погоды на сегодня исчерпан. Пока доступны только: %s",
+			strings.Join(h.config().Cities, ", "))
+	}
+
+	h.replyLimited(update, text)
+	return false
+}
+
+// Reply to update of limited user
+func (h *Handler) replyLimited(update tgbotapi.Update, text string) {
+	switch {
+	case update.Message != nil:
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+		msg.ReplyToMessageID = update.Message.MessageID
+		h.messenger.Send(msg)
+	case update.CallbackQuery != nil:
+		h.messenger.Request(tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, text))
+	}
+}
+
+// Router rate limit reply
+func (h *Handler) tooOften(ctx context.Context, update tgbotapi.Update) {
+	h.replyLimited(update, tooOftenText)
+}
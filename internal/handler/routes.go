@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/router"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+// Register handlers of commands, buttons and other updates
+func (h *Handler) routes() *router.Router {
+	r := router.New(h.bot.Self.UserName)
+
+	r.Use(
+		router.Logging(h.log),
+		router.Recovery(h.log),
+		router.Metrics(),
+		h.dropBanned,
+		router.RateLimit(ratelimit.New(h.config().RateLimit.Rate, h.config().RateLimit.Burst), h.tooOften),
+		h.rememberUser,
+	)
+
+	// Commands
+	r.Command("start", "Начать", h.commandStart)
+	r.Command("weather", "Погода сейчас: /weather <город>", h.commandWeather)
+	r.Command("forecast", "Прогноз на 5 дней", h.messageForecast)
+	r.Command("hourly", "Прогноз по часам", h.messageHourlyForecast)
+	r.Command("fav", "Избранное: /fav add|remove|list", h.messageFavourites)
+	r.Command("history", "Погода в прошлом: /history <город> <дд.мм.гггг>", h.commandHistory)
+	r.Command("trend", "Температура за неделю: /trend <город>", h.commandTrend)
+	r.Command("export", "Прогноз файлом: /export ics|csv", h.commandExport)
+	r.Command("settings", "Настройки чата", h.commandSettings)
+	r.Command("setlocation", "Город группы: /setlocation <город>", h.messageSetLocation)
+	r.Command("help", "Помощь", h.commandHelp)
+
+	// Admin commands are not published
+	r.Command("ban", "", h.adminOnly(h.commandBan))
+	r.Command("unban", "", h.adminOnly(h.commandUnban))
+	r.Command("bans", "", h.adminOnly(h.commandBans))
+	r.Command("stats", "", h.adminOnly(h.commandStats))
+	r.Command("cache", "", h.adminOnly(h.commandCache))
+	r.Command("broadcast", "", h.adminOnly(h.commandBroadcast))
+	r.Command("reload", "", h.adminOnly(h.commandReload))
+	r.Command("accuracy", "", h.adminOnly(h.commandAccuracy))
+
+	// Reply buttons
+	r.Text("Назад", h.commandStart)
+	r.Text("Ввести вручную", func(ctx context.Context, update tgbotapi.Update) {
+		h.messageOther(update.Message.Chat.ID)
+	})
+	r.Text("Прогноз", h.messageForecast)
+	r.Text("По часам", h.messageHourlyForecast)
+	r.Text(favouriteButton, h.messageAddFavourite)
+	r.Text(historyButton, h.messageYearAgo)
+	if h.hfClient != nil {
+		r.Text("Иллюстрация", h.messageIllustration)
+	}
+
+	// Inline buttons
+	r.Callback(hourlyPrefix, h.callbackHourly)
+	r.Callback(noopCallback, h.callbackNoop)
+
+	r.InlineQuery(h.handlerInline)
+	r.Fallback(h.messageText)
+
+	return r
+}
+
+// Remember user of every message
+func (h *Handler) rememberUser(next router.HandlerFunc) router.HandlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		if update.Message != nil && update.Message.From != nil {
+			if err := h.storage.SaveUser(ctx, storage.User{
+				ID:        update.Message.From.ID,
+				UserName:  update.Message.From.UserName,
+				FirstName: update.Message.From.FirstName,
+			}); err != nil {
+				h.log.Error(err.Error())
+			}
+		}
+
+		next(ctx, update)
+	}
+}
+
+// /start and "Назад"
+func (h *Handler) commandStart(ctx context.Context, update tgbotapi.Update) {
+	if isGroup(update.Message.Chat) {
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, groupHelp))
+		return
+	}
+
+	h.messageStart(ctx, update.Message.Chat.ID, update.Message.From.ID)
+}
+
+// /help message
+func (h *Handler) commandHelp(ctx context.Context, update tgbotapi.Update) {
+	var text strings.Builder
+
+	text.WriteString("Погода в населенном пункте по запросу.\n\n")
+	for _, command := range h.router.BotCommands() {
+		text.WriteString(fmt.Sprintf("/%s — %s\n", command.Command, command.Description))
+	}
+	text.WriteString(fmt.Sprintf("\nВ любом чате: @%s <город>", h.bot.Self.UserName))
+
+	h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text.String()))
+}
+
+// /settings message
+func (h *Handler) commandSettings(ctx context.Context, update tgbotapi.Update) {
+	settings, err := h.storage.ChatSettings(ctx, update.Message.Chat.ID)
+	if err != nil {
+		h.log.Error(err.Error())
+		h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить настройки, попробуйте позже"))
+		return
+	}
+
+	city := settings.City
+	if city == "" {
+		city = "не задан"
+	}
+
+	h.messenger.Send(tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf(
+		"Настройки чата:\nЯзык: %s\nЕдиницы: %s\nЧасовой пояс: %s\nГород по умолчанию: %s\n\n"+
+			"Город группы задает администратор командой /setlocation <город>, избранное — /fav",
+		settings.Language, settings.Units, settings.Timezone, city)))
+}
+
+// Any other text: city name, in groups only replies to the bot and mentions
+func (h *Handler) messageText(ctx context.Context, update tgbotapi.Update) {
+	if isGroup(update.Message.Chat) {
+		if city, ok := h.addressedText(update.Message); ok {
+			h.messageWeather(ctx, update, city)
+		}
+		return
+	}
+
+	if strings.TrimSpace(update.Message.Text) == "" {
+		return
+	}
+
+	h.messageWeather(ctx, update, update.Message.Text)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	f "github.com/m1al04949/weatherbot/internal/lib/format"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/services/weatherservice"
+)
+
+const trendDays = 7
+
+// /trend <city>, the chat's location without city
+func (h *Handler) commandTrend(ctx context.Context, update tgbotapi.Update) {
+	message := update.Message
+
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyToMessageID = message.MessageID
+		h.messenger.Send(msg)
+	}
+
+	var (
+		location models.CordinatesResponse
+		ok       bool
+	)
+	if city := strings.TrimSpace(message.CommandArguments()); city != "" {
+		// Observations are kept by place, the city is geocoded
+		var err error
+		location, err = h.weather.Locate(ctx, weatherservice.Query{City: city, Allow: h.providerAllowed(update)})
+		if errors.Is(err, weatherservice.ErrRateLimited) {
+			return
+		}
+		if err != nil {
+			h.log.Error(err.Error())
+			reply("Такой населенный пункт не найден")
+			return
+		}
+	} else if location, ok = h.weather.Location(ctx, message.Chat.ID); !ok {
+		reply("Использование: /trend <город>")
+		return
+	}
+	city := locationTitle(location)
+	if location.Name == "" {
+		city = fmt.Sprintf("%.2f, %.2f", location.Lat, location.Lon)
+	}
+
+	days, err := h.observations.Trend(ctx, location.Lat, location.Lon, trendDays, time.Now())
+	if err != nil {
+		h.log.Error(err.Error())
+		reply("Не удалось получить данные, попробуйте позже")
+		return
+	}
+	if len(days) == 0 {
+		reply(fmt.Sprintf("Нет сохраненных наблюдений для %s. Запросите погоду, и бот начнет их собирать", city))
+		return
+	}
+
+	text, ok := h.render(f.TemplateTrend, f.Trend{Name: city, Days: days})
+	if !ok {
+		reply(renderFailedText)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = message.MessageID
+	h.messenger.Send(msg)
+}
@@ -0,0 +1,257 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"github.com/m1al04949/weatherbot/internal/lib/condition"
+	"github.com/m1al04949/weatherbot/internal/lib/forecast"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width  = 800
+	height = 450
+
+	marginLeft   = 50
+	marginRight  = 20
+	iconsTop     = 10
+	iconsHeight  = 60
+	chartTop     = 90
+	chartBottom  = 330
+	precipTop    = 350
+	precipBottom = 420
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	gridColor  = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	textColor  = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	tempColor  = color.RGBA{0xe6, 0x55, 0x2e, 0xff}
+	rainColor  = color.RGBA{0x3a, 0x7b, 0xd5, 0xff}
+	sunColor   = color.RGBA{0xf5, 0xb8, 0x00, 0xff}
+	cloudColor = color.RGBA{0x9e, 0xa7, 0xb0, 0xff}
+	snowColor  = color.RGBA{0x8e, 0xc9, 0xf0, 0xff}
+)
+
+// Go Regular has Cyrillic unlike the basic bitmap font, parsed once and shared:
+// faces aren't safe for concurrent use, so every chart gets its own
+var regular = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+// Render 5-day forecast as PNG: temperature line, precipitation bars and condition icons
+func Render(items []models.Weather) ([]byte, error) {
+	op := "lib.chart.render"
+
+	if len(items) < 2 {
+		return nil, fmt.Errorf("%s: not enough forecast slots: %d", op, len(items))
+	}
+
+	face, err := newFace()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer face.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	// Scales
+	minTemp, maxTemp, maxPrecip := items[0].Temp, items[0].Temp, 1.0
+	for _, item := range items {
+		minTemp = math.Min(minTemp, item.Temp)
+		maxTemp = math.Max(maxTemp, item.Temp)
+		maxPrecip = math.Max(maxPrecip, item.Precipitation)
+	}
+	minTemp, maxTemp = math.Floor(minTemp)-1, math.Ceil(maxTemp)+1
+
+	step := float64(width-marginLeft-marginRight) / float64(len(items)-1)
+	x := func(i int) int {
+		return marginLeft + int(math.Round(float64(i)*step))
+	}
+	y := func(temp float64) int {
+		return chartBottom - int(math.Round((temp-minTemp)/(maxTemp-minTemp)*(chartBottom-chartTop)))
+	}
+
+	// Horizontal grid with temperature labels
+	for _, temp := range gridValues(minTemp, maxTemp) {
+		line(img, marginLeft, y(temp), width-marginRight, y(temp), 1, gridColor)
+		label(img, face, 5, y(temp)+4, fmt.Sprintf("%+.0f", temp))
+	}
+	if minTemp < 0 && maxTemp > 0 {
+		line(img, marginLeft, y(0), width-marginRight, y(0), 1, cloudColor)
+	}
+
+	// Days: separators, dates and condition icons
+	index := 0
+	for _, day := range forecast.GroupByDay(items) {
+		left := x(index)
+		right := x(min(index+len(day), len(items)-1))
+		if index > 0 {
+			line(img, left, iconsTop, left, precipBottom, 1, gridColor)
+		}
+
+		daily := forecast.Daily(day)[0]
+		if right-left > 40 {
+			icon(img, (left+right)/2, iconsTop+iconsHeight/2-8, daily.Code)
+			date := day[0].Date.Format("02.01")
+			label(img, face, (left+right-font.MeasureString(face, date).Round())/2, iconsTop+iconsHeight+8, date)
+		}
+		index += len(day)
+	}
+
+	// Precipitation bars
+	barWidth := max(int(step)-2, 1)
+	for i, item := range items {
+		if item.Precipitation <= 0 {
+			continue
+		}
+		top := precipBottom - int(math.Round(item.Precipitation/maxPrecip*(precipBottom-precipTop)))
+		fillRect(img, x(i)-barWidth/2, top, x(i)+barWidth/2+1, precipBottom, rainColor)
+	}
+	line(img, marginLeft, precipBottom, width-marginRight, precipBottom, 1, gridColor)
+	label(img, face, 5, precipBottom, fmt.Sprintf("%.0f мм", maxPrecip))
+
+	// Temperature line
+	for i := 1; i < len(items); i++ {
+		line(img, x(i-1), y(items[i-1].Temp), x(i), y(items[i].Temp), 3, tempColor)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Round values for horizontal grid lines
+func gridValues(minTemp, maxTemp float64) []float64 {
+	step := math.Max(1, math.Ceil((maxTemp-minTemp)/5))
+
+	var values []float64
+	for temp := math.Ceil(minTemp/step) * step; temp <= maxTemp; temp += step {
+		values = append(values, temp)
+	}
+
+	return values
+}
+
+func newFace() (font.Face, error) {
+	regularFont, err := regular()
+	if err != nil {
+		return nil, err
+	}
+
+	return opentype.NewFace(regularFont, &opentype.FaceOptions{Size: 12, DPI: 72, Hinting: font.HintingFull})
+}
+
+func label(img *image.RGBA, face font.Face, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// Simple condition icon centered in (cx, cy)
+func icon(img *image.RGBA, cx, cy int, code int) {
+	switch {
+	case condition.GroupOf(code) == condition.Clear:
+		circle(img, cx, cy, 12, sunColor)
+		for a := 0.0; a < 2*math.Pi; a += math.Pi / 4 {
+			line(img, cx+int(16*math.Cos(a)), cy+int(16*math.Sin(a)),
+				cx+int(21*math.Cos(a)), cy+int(21*math.Sin(a)), 2, sunColor)
+		}
+	case code == 801 || code == 802:
+		circle(img, cx+8, cy-6, 10, sunColor)
+		cloud(img, cx-3, cy+4)
+	case condition.GroupOf(code) == condition.Thunderstorm:
+		cloud(img, cx, cy)
+		line(img, cx+2, cy+10, cx-4, cy+18, 2, sunColor)
+		line(img, cx-4, cy+18, cx+4, cy+18, 2, sunColor)
+		line(img, cx+4, cy+18, cx-2, cy+26, 2, sunColor)
+	case condition.GroupOf(code) == condition.Snow:
+		cloud(img, cx, cy)
+		for _, dx := range []int{-8, 0, 8} {
+			circle(img, cx+dx, cy+18, 2, snowColor)
+		}
+	case condition.GroupOf(code) == condition.Rain, condition.GroupOf(code) == condition.Drizzle:
+		cloud(img, cx, cy)
+		for _, dx := range []int{-8, 0, 8} {
+			line(img, cx+dx+2, cy+12, cx+dx-2, cy+22, 2, rainColor)
+		}
+	default:
+		cloud(img, cx, cy)
+	}
+}
+
+func cloud(img *image.RGBA, cx, cy int) {
+	circle(img, cx-9, cy+2, 8, cloudColor)
+	circle(img, cx+1, cy-3, 10, cloudColor)
+	circle(img, cx+10, cy+3, 7, cloudColor)
+	fillRect(img, cx-9, cy+3, cx+11, cy+11, cloudColor)
+}
+
+func circle(img *image.RGBA, cx, cy, r int, c color.Color) {
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				img.Set(cx+dx, cy+dy, c)
+			}
+		}
+	}
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// Bresenham line with square pen of given thickness
+func line(img *image.RGBA, x0, y0, x1, y1, thickness int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+
+	for {
+		fillRect(img, x0-thickness/2, y0-thickness/2, x0-thickness/2+thickness, y0-thickness/2+thickness, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
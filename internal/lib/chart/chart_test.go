@@ -0,0 +1,187 @@
+package chart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+// 5 days of 3-hour slots with temperatures from temp
+func slots(temp func(i int) float64) []models.Weather {
+	start := time.Date(2025, 10, 20, 0, 0, 0, 0, time.UTC)
+
+	var result []models.Weather
+	for i := range 40 {
+		result = append(result, models.Weather{
+			Date: start.Add(time.Duration(i) * 3 * time.Hour),
+			Temp: temp(i),
+			Code: 800,
+		})
+	}
+
+	return result
+}
+
+func render(t *testing.T, items []models.Weather) *image.RGBA {
+	t.Helper()
+
+	data, err := Render(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewRGBA(decoded.Bounds())
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	return img
+}
+
+// Bounds of pixels of color c within area
+func colorBounds(img *image.RGBA, area image.Rectangle, c color.RGBA) image.Rectangle {
+	var bounds image.Rectangle
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if img.RGBAAt(x, y) == c {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	return bounds
+}
+
+func TestRenderTemperature(t *testing.T) {
+	tests := []struct {
+		name string
+		temp func(i int) float64
+		want image.Rectangle
+	}{
+		{
+			// Scale is -4..6, 24 px per degree
+			name: "range",
+			temp: func(i int) float64 { return float64(i%9) - 3 },
+			want: image.Rect(49, 113, 782, 308),
+		},
+		{
+			// Scale is one degree around, the line is in the middle
+			name: "flat",
+			temp: func(i int) float64 { return -5 },
+			want: image.Rect(49, 209, 782, 212),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := render(t, slots(tt.temp))
+
+			if img.Bounds() != image.Rect(0, 0, width, height) {
+				t.Errorf("size: got %v", img.Bounds())
+			}
+			if got := colorBounds(img, img.Bounds(), tempColor); got != tt.want {
+				t.Errorf("temperature line: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPrecipitation(t *testing.T) {
+	tests := []struct {
+		name   string
+		precip []float64
+		want   []int // tops of bars
+	}{
+		{name: "scaled to maximum", precip: []float64{4, 2, 1}, want: []int{precipTop, 385, 402}},
+		{name: "light rain is scaled to 1 mm", precip: []float64{0.5}, want: []int{385}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := slots(func(i int) float64 { return 0 })
+			for i, precip := range tt.precip {
+				items[i+1].Precipitation = precip
+			}
+
+			img := render(t, items)
+
+			step := float64(width-marginLeft-marginRight) / float64(len(items)-1)
+			for i, want := range tt.want {
+				x := marginLeft + int(float64(i+1)*step+0.5)
+				bar := colorBounds(img, image.Rect(x, chartBottom, x+1, height), rainColor)
+				if bar.Min.Y != want || bar.Max.Y != precipBottom {
+					t.Errorf("bar %d: got %v, want from %d to %d", i, bar, want, precipBottom)
+				}
+			}
+
+			// Dry slots have no bars
+			x := marginLeft + int(float64(len(tt.precip)+2)*step+0.5)
+			if bar := colorBounds(img, image.Rect(x, chartBottom, x+1, height), rainColor); !bar.Empty() {
+				t.Errorf("dry slot: got bar %v", bar)
+			}
+		})
+	}
+}
+
+func TestRenderNotEnoughSlots(t *testing.T) {
+	for _, items := range [][]models.Weather{nil, slots(func(i int) float64 { return 1 })[:1]} {
+		if _, err := Render(items); err == nil {
+			t.Errorf("%d slots: no error", len(items))
+		}
+	}
+}
+
+func TestGridValues(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		want     []float64
+	}{
+		{min: -4, max: 6, want: []float64{-4, -2, 0, 2, 4, 6}},
+		{min: -6, max: -4, want: []float64{-6, -5, -4}},
+		{min: 3, max: 30, want: []float64{6, 12, 18, 24, 30}},
+	}
+
+	for _, tt := range tests {
+		if got := gridValues(tt.min, tt.max); !slices.Equal(got, tt.want) {
+			t.Errorf("%v..%v: got %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestLabelCyrillic(t *testing.T) {
+	face, err := newFace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	// Missing glyphs would be drawn as boxes
+	if _, ok := face.GlyphAdvance('м'); !ok {
+		t.Fatal("font has no cyrillic glyphs")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+	label(img, face, 2, 15, "мм")
+
+	var drawn int
+	for y := range 20 {
+		for x := range 40 {
+			if img.RGBAAt(x, y) != background {
+				drawn++
+			}
+		}
+	}
+	if drawn == 0 {
+		t.Error("cyrillic label is not drawn")
+	}
+}
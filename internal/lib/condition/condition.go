@@ -0,0 +1,106 @@
+package condition
+
+import "strings"
+
+// Group of OpenWeather condition codes, see https://openweathermap.org/weather-conditions
+type Group int
+
+const (
+	Unknown Group = iota
+	Thunderstorm
+	Drizzle
+	Rain
+	Snow
+	Atmosphere
+	Clear
+	Clouds
+)
+
+// Group by code: 2xx thunderstorm, 3xx drizzle, 5xx rain, 6xx snow,
+// 7xx atmosphere, 800 clear, 80x clouds
+func GroupOf(code int) Group {
+	switch {
+	case code >= 200 && code < 300:
+		return Thunderstorm
+	case code >= 300 && code < 400:
+		return Drizzle
+	case code >= 500 && code < 600:
+		return Rain
+	case code >= 600 && code < 700:
+		return Snow
+	case code >= 700 && code < 800:
+		return Atmosphere
+	case code == 800:
+		return Clear
+	case code > 800 && code < 900:
+		return Clouds
+	default:
+		return Unknown
+	}
+}
+
+// Night icons of OpenWeather end with "n", e.g. "01n"
+func IsNight(icon string) bool {
+	return strings.HasSuffix(icon, "n")
+}
+
+// Emoji of condition code at day or night
+func Emoji(code int, night bool) string {
+	switch code {
+	// Thunderstorm with rain
+	case 200, 201, 202, 230, 231, 232:
+		return "⛈️"
+	// Thunderstorm without rain
+	case 210, 211, 212, 221:
+		return "🌩️"
+	// Light rain
+	case 300, 301, 310, 500:
+		return "☔"
+	// Rain and drizzle
+	case 302, 311, 312, 501, 502, 503, 504:
+		return "🌧️"
+	// Showers, the sun may show up between them
+	case 313, 314, 321, 520, 521, 522, 531:
+		if night {
+			return "🌧️"
+		}
+		return "🌦️"
+	// Snow, sleet and freezing rain
+	case 511, 600, 601, 602, 611, 612, 613, 615, 616, 620, 621, 622:
+		return "🌨️"
+	case 701, 711, 721, 741:
+		return "🌫️"
+	case 731, 751, 761:
+		return "🏜️"
+	case 762:
+		return "🌋"
+	case 771:
+		return "💨"
+	case 781:
+		return "🌪️"
+	case 800:
+		if night {
+			return "🌙"
+		}
+		return "☀️"
+	case 801:
+		if night {
+			return "🌙"
+		}
+		return "🌤️"
+	case 802:
+		if night {
+			return "☁️"
+		}
+		return "⛅"
+	case 803:
+		if night {
+			return "☁️"
+		}
+		return "🌥️"
+	case 804:
+		return "☁️"
+	default:
+		return "🌈"
+	}
+}
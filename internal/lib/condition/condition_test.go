@@ -0,0 +1,134 @@
+package condition
+
+import "testing"
+
+// Full table of OpenWeather condition codes, https://openweathermap.org/weather-conditions
+var codes = []struct {
+	code  int
+	group Group
+	day   string
+	night string
+}{
+	{200, Thunderstorm, "⛈️", "⛈️"},
+	{201, Thunderstorm, "⛈️", "⛈️"},
+	{202, Thunderstorm, "⛈️", "⛈️"},
+	{210, Thunderstorm, "🌩️", "🌩️"},
+	{211, Thunderstorm, "🌩️", "🌩️"},
+	{212, Thunderstorm, "🌩️", "🌩️"},
+	{221, Thunderstorm, "🌩️", "🌩️"},
+	{230, Thunderstorm, "⛈️", "⛈️"},
+	{231, Thunderstorm, "⛈️", "⛈️"},
+	{232, Thunderstorm, "⛈️", "⛈️"},
+
+	{300, Drizzle, "☔", "☔"},
+	{301, Drizzle, "☔", "☔"},
+	{302, Drizzle, "🌧️", "🌧️"},
+	{310, Drizzle, "☔", "☔"},
+	{311, Drizzle, "🌧️", "🌧️"},
+	{312, Drizzle, "🌧️", "🌧️"},
+	{313, Drizzle, "🌦️", "🌧️"},
+	{314, Drizzle, "🌦️", "🌧️"},
+	{321, Drizzle, "🌦️", "🌧️"},
+
+	{500, Rain, "☔", "☔"},
+	{501, Rain, "🌧️", "🌧️"},
+	{502, Rain, "🌧️", "🌧️"},
+	{503, Rain, "🌧️", "🌧️"},
+	{504, Rain, "🌧️", "🌧️"},
+	{511, Rain, "🌨️", "🌨️"},
+	{520, Rain, "🌦️", "🌧️"},
+	{521, Rain, "🌦️", "🌧️"},
+	{522, Rain, "🌦️", "🌧️"},
+	{531, Rain, "🌦️", "🌧️"},
+
+	{600, Snow, "🌨️", "🌨️"},
+	{601, Snow, "🌨️", "🌨️"},
+	{602, Snow, "🌨️", "🌨️"},
+	{611, Snow, "🌨️", "🌨️"},
+	{612, Snow, "🌨️", "🌨️"},
+	{613, Snow, "🌨️", "🌨️"},
+	{615, Snow, "🌨️", "🌨️"},
+	{616, Snow, "🌨️", "🌨️"},
+	{620, Snow, "🌨️", "🌨️"},
+	{621, Snow, "🌨️", "🌨️"},
+	{622, Snow, "🌨️", "🌨️"},
+
+	{701, Atmosphere, "🌫️", "🌫️"},
+	{711, Atmosphere, "🌫️", "🌫️"},
+	{721, Atmosphere, "🌫️", "🌫️"},
+	{731, Atmosphere, "🏜️", "🏜️"},
+	{741, Atmosphere, "🌫️", "🌫️"},
+	{751, Atmosphere, "🏜️", "🏜️"},
+	{761, Atmosphere, "🏜️", "🏜️"},
+	{762, Atmosphere, "🌋", "🌋"},
+	{771, Atmosphere, "💨", "💨"},
+	{781, Atmosphere, "🌪️", "🌪️"},
+
+	{800, Clear, "☀️", "🌙"},
+
+	{801, Clouds, "🌤️", "🌙"},
+	{802, Clouds, "⛅", "☁️"},
+	{803, Clouds, "🌥️", "☁️"},
+	{804, Clouds, "☁️", "☁️"},
+}
+
+func TestGroupOf(t *testing.T) {
+	for _, tt := range codes {
+		if got := GroupOf(tt.code); got != tt.group {
+			t.Errorf("GroupOf(%d) = %d, want %d", tt.code, got, tt.group)
+		}
+	}
+
+	for _, code := range []int{0, 100, 199, 400, 450, 900, 1000, -1} {
+		if got := GroupOf(code); got != Unknown {
+			t.Errorf("GroupOf(%d) = %d, want Unknown", code, got)
+		}
+	}
+}
+
+func TestEmoji(t *testing.T) {
+	for _, tt := range codes {
+		if got := Emoji(tt.code, false); got != tt.day {
+			t.Errorf("Emoji(%d, day) = %s, want %s", tt.code, got, tt.day)
+		}
+		if got := Emoji(tt.code, true); got != tt.night {
+			t.Errorf("Emoji(%d, night) = %s, want %s", tt.code, got, tt.night)
+		}
+	}
+}
+
+// Codes outside of the table fall back to the default emoji
+func TestEmojiUnknown(t *testing.T) {
+	known := make(map[int]bool, len(codes))
+	for _, tt := range codes {
+		known[tt.code] = true
+	}
+
+	for code := 0; code < 1000; code++ {
+		if known[code] {
+			continue
+		}
+		if got := Emoji(code, false); got != "🌈" {
+			t.Errorf("Emoji(%d) = %s, want default", code, got)
+		}
+	}
+}
+
+func TestIsNight(t *testing.T) {
+	tests := []struct {
+		icon string
+		want bool
+	}{
+		{"01d", false},
+		{"01n", true},
+		{"10n", true},
+		{"50d", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsNight(tt.icon); got != tt.want {
+			t.Errorf("IsNight(%q) = %v, want %v", tt.icon, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+const (
+	// RFC 5545 limit of content line length in octets
+	icsLineLimit = 75
+	icsDate      = "20060102"
+	icsTimestamp = "20060102T150405Z"
+)
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// iCalendar with one all-day event per forecast day
+func ICS(name string, days []models.DailyForecast, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	line := func(s string) {
+		buf.WriteString(fold(s))
+		buf.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//weatherbot//forecast//RU")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + icsEscaper.Replace("Погода: "+name))
+
+	stamp := now.UTC().Format(icsTimestamp)
+	for _, day := range days {
+		start := day.Date.Format(icsDate)
+		end := day.Date.AddDate(0, 0, 1).Format(icsDate)
+
+		summary := fmt.Sprintf("%s: %s, %d…%d°C",
+			name, day.Description, int(math.Round(day.MinTemp)), int(math.Round(day.MaxTemp)))
+		description := fmt.Sprintf("Осадки: %.1f мм\nВетер до %d м/с",
+			day.Precipitation, int(math.Round(day.MaxSpeed)))
+
+		line("BEGIN:VEVENT")
+		// Same day and place replace the event on repeated import
+		line(fmt.Sprintf("UID:%s-%s@weatherbot", start, uidPart(name)))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + start)
+		line("DTEND;VALUE=DATE:" + end)
+		line("SUMMARY:" + icsEscaper.Replace(summary))
+		line("DESCRIPTION:" + icsEscaper.Replace(description))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// CSV with raw forecast slots
+func CSV(items []models.Weather) ([]byte, error) {
+	op := "lib.export.csv"
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{{"time", "temp", "humidity", "wind_speed", "precipitation", "code", "description"}}
+	for _, item := range items {
+		records = append(records, []string{
+			item.Date.Format(time.RFC3339),
+			strconv.FormatFloat(item.Temp, 'f', 1, 64),
+			strconv.FormatInt(item.Humidity, 10),
+			strconv.FormatFloat(item.Speed, 'f', 1, 64),
+			strconv.FormatFloat(item.Precipitation, 'f', 1, 64),
+			strconv.Itoa(item.Code),
+			item.Description,
+		})
+	}
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Split content line into lines of at most 75 octets without breaking runes
+func fold(s string) string {
+	if len(s) <= icsLineLimit {
+		return s
+	}
+
+	var b strings.Builder
+	limit := icsLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space
+		limit = icsLineLimit - 1
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
+
+func uidPart(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, run with -update to see the diff:\n%s", name, got)
+	}
+}
+
+func TestICS(t *testing.T) {
+	spb := time.FixedZone("MSK", 3*60*60)
+	days := []models.DailyForecast{
+		{
+			Date: time.Date(2025, 12, 31, 0, 0, 0, 0, spb), MinTemp: -7.6, MaxTemp: -2.4,
+			Code: 601, Description: "снег", Precipitation: 4.25, MaxSpeed: 8.6,
+		},
+		{
+			Date: time.Date(2026, 1, 1, 0, 0, 0, 0, spb), MinTemp: -0.4, MaxTemp: 1.5,
+			Code: 511, Description: "ледяной дождь; гололед, осторожно", Precipitation: 0, MaxSpeed: 12.2,
+		},
+	}
+
+	got := ICS(`Санкт-Петербург, Ленинградская область\RU`, days, time.Date(2025, 12, 30, 15, 4, 5, 0, spb))
+	golden(t, "forecast.ics", got)
+
+	lines := strings.Split(strings.TrimSuffix(string(got), "\r\n"), "\r\n")
+	for _, line := range lines {
+		if len(line) > icsLineLimit || !utf8.ValidString(line) {
+			t.Errorf("line over %d octets or with broken rune: %q", icsLineLimit, line)
+		}
+	}
+
+	// Event ends the next day, the last day of year included
+	unfolded := strings.ReplaceAll(string(got), "\r\n ", "")
+	for _, want := range []string{
+		"DTSTART;VALUE=DATE:20251231\r\nDTEND;VALUE=DATE:20260101\r\n",
+		"DTSTART;VALUE=DATE:20260101\r\nDTEND;VALUE=DATE:20260102\r\n",
+		`X-WR-CALNAME:Погода: Санкт-Петербург\, Ленинградская область\\RU` + "\r\n",
+		`SUMMARY:Санкт-Петербург\, Ленинградская область\\RU: ледяной дождь\; гололед\, осторожно\, 0…2°C`,
+		`DESCRIPTION:Осадки: 4.2 мм\nВетер до 9 м/с` + "\r\n",
+		"DTSTAMP:20251230T120405Z\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar doesn't contain %q", want)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []int // lengths of folded lines in octets
+	}{
+		{name: "short", line: strings.Repeat("a", 75), want: []int{75}},
+		{name: "one octet over", line: strings.Repeat("a", 76), want: []int{75, 2}},
+		{name: "continuation lines", line: strings.Repeat("a", 75+74+1), want: []int{75, 75, 2}},
+		// 2-octet runes can't end at odd limit
+		{name: "cyrillic", line: strings.Repeat("ж", 40), want: []int{74, 7}},
+		{name: "4-octet runes", line: "a" + strings.Repeat("😀", 20), want: []int{73, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.line)
+
+			var lengths []int
+			for _, line := range strings.Split(folded, "\r\n") {
+				lengths = append(lengths, len(line))
+				if !utf8.ValidString(line) {
+					t.Errorf("broken rune in %q", line)
+				}
+			}
+			if strings.ReplaceAll(folded, "\r\n ", "") != tt.line {
+				t.Errorf("unfolded line differs: %q", folded)
+			}
+			if len(lengths) != len(tt.want) {
+				t.Fatalf("lengths: got %v, want %v", lengths, tt.want)
+			}
+			for i := range lengths {
+				if lengths[i] != tt.want[i] {
+					t.Errorf("lengths: got %v, want %v", lengths, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCSV(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	got, err := CSV([]models.Weather{
+		{
+			Date: time.Date(2025, 10, 20, 3, 0, 0, 0, msk), Temp: 4.14, Humidity: 90, Speed: 2.05,
+			Code: 800, Description: "ясно",
+		},
+		{
+			Date: time.Date(2025, 10, 20, 6, 0, 0, 0, msk), Temp: -0.05, Humidity: 95, Speed: 4.2,
+			Precipitation: 1.87, Code: 616, Description: `дождь со снегом, "мокрый"`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden(t, "forecast.csv", got)
+}
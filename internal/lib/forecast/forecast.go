@@ -0,0 +1,96 @@
+package forecast
+
+import (
+	"math"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+// Group 3-hour slots by local date
+func GroupByDay(forecast []models.Weather) [][]models.Weather {
+	var days [][]models.Weather
+	lastDate := ""
+
+	for _, item := range forecast {
+		date := item.Date.Format(time.DateOnly)
+		if date != lastDate {
+			days = append(days, nil)
+			lastDate = date
+		}
+		days[len(days)-1] = append(days[len(days)-1], item)
+	}
+
+	return days
+}
+
+// Aggregate 3-hour slots into daily min/max
+func Daily(forecast []models.Weather) []models.DailyForecast {
+	var result []models.DailyForecast
+
+	for _, day := range GroupByDay(forecast) {
+		result = append(result, aggregate(day))
+	}
+
+	return result
+}
+
+// Split forecast into rest of today slots and next days aggregates.
+// "Today" is the local date of now in the location's timezone.
+func Split(forecast []models.Weather, now time.Time) ([]models.Weather, []models.DailyForecast) {
+	var (
+		today    []models.Weather
+		nextDays []models.DailyForecast
+	)
+
+	if len(forecast) == 0 {
+		return today, nextDays
+	}
+
+	localNow := now.In(forecast[0].Date.Location())
+	todayDate := localNow.Format(time.DateOnly)
+
+	for _, item := range forecast {
+		if item.Date.Format(time.DateOnly) == todayDate && item.Date.After(localNow) {
+			today = append(today, item)
+		}
+	}
+
+	for _, day := range Daily(forecast) {
+		if day.Date.Format(time.DateOnly) > todayDate {
+			nextDays = append(nextDays, day)
+		}
+	}
+
+	return today, nextDays
+}
+
+func aggregate(items []models.Weather) models.DailyForecast {
+	dayTime := items[0].Date
+
+	daily := models.DailyForecast{
+		Date:    time.Date(dayTime.Year(), dayTime.Month(), dayTime.Day(), 0, 0, 0, 0, dayTime.Location()),
+		MinTemp: math.Inf(1),
+		MaxTemp: math.Inf(-1),
+	}
+
+	conditions := make(map[string]int)
+	dominant := 0
+
+	for _, item := range items {
+		daily.MinTemp = math.Min(daily.MinTemp, item.Temp)
+		daily.MaxTemp = math.Max(daily.MaxTemp, item.Temp)
+		daily.MaxSpeed = math.Max(daily.MaxSpeed, item.Speed)
+		daily.Precipitation += item.Precipitation
+
+		// The most frequent condition, the first to reach it wins a tie
+		conditions[item.Description]++
+		if conditions[item.Description] > dominant {
+			dominant = conditions[item.Description]
+			daily.Code = item.Code
+			daily.Description = item.Description
+		}
+	}
+
+	return daily
+}
@@ -0,0 +1,157 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+// 5 days of 3-hour slots starting at UTC start, as OpenWeather returns them
+func slots(start time.Time, offset int) []models.Weather {
+	location := time.FixedZone("", offset)
+
+	var result []models.Weather
+	for i := range 40 {
+		result = append(result, models.Weather{
+			Date:        start.Add(time.Duration(i) * 3 * time.Hour).In(location),
+			Description: "ясно",
+			Temp:        float64(i),
+		})
+	}
+
+	return result
+}
+
+func TestSplit(t *testing.T) {
+	start := time.Date(2025, 10, 20, 18, 0, 0, 0, time.UTC)
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name         string
+		offset       int
+		now          time.Time
+		wantToday    []string
+		wantNextDays []string
+	}{
+		{
+			name:         "utc",
+			offset:       0,
+			now:          time.Date(2025, 10, 20, 16, 0, 0, 0, time.UTC),
+			wantToday:    []string{"18:00", "21:00"},
+			wantNextDays: []string{"2025-10-21", "2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "moscow",
+			offset:       3 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 19, 0, 0, 0, moscow),
+			wantToday:    []string{"21:00"},
+			wantNextDays: []string{"2025-10-21", "2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "moscow late evening",
+			offset:       3 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 23, 30, 0, 0, moscow),
+			wantToday:    nil,
+			wantNextDays: []string{"2025-10-21", "2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "orsk with moscow server",
+			offset:       5 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 19, 0, 0, 0, moscow),
+			wantToday:    []string{"23:00"},
+			wantNextDays: []string{"2025-10-21", "2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "orsk after local midnight",
+			offset:       5 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 22, 30, 0, 0, moscow),
+			wantToday:    []string{"02:00", "05:00", "08:00", "11:00", "14:00", "17:00", "20:00", "23:00"},
+			wantNextDays: []string{"2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "new york",
+			offset:       -4 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 16, 0, 0, 0, time.UTC),
+			wantToday:    []string{"14:00", "17:00", "20:00", "23:00"},
+			wantNextDays: []string{"2025-10-21", "2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25"},
+		},
+		{
+			name:         "kamchatka",
+			offset:       12 * 60 * 60,
+			now:          time.Date(2025, 10, 20, 16, 0, 0, 0, time.UTC),
+			wantToday:    []string{"06:00", "09:00", "12:00", "15:00", "18:00", "21:00"},
+			wantNextDays: []string{"2025-10-22", "2025-10-23", "2025-10-24", "2025-10-25", "2025-10-26"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			today, nextDays := Split(slots(start, tt.offset), tt.now)
+
+			if len(today) != len(tt.wantToday) {
+				t.Fatalf("today: got %d slots, want %d", len(today), len(tt.wantToday))
+			}
+			for i, item := range today {
+				if got := item.Date.Format("15:04"); got != tt.wantToday[i] {
+					t.Errorf("today[%d]: got %s, want %s", i, got, tt.wantToday[i])
+				}
+			}
+
+			if len(nextDays) != len(tt.wantNextDays) {
+				t.Fatalf("next days: got %d days, want %d", len(nextDays), len(tt.wantNextDays))
+			}
+			for i, day := range nextDays {
+				if got := day.Date.Format(time.DateOnly); got != tt.wantNextDays[i] {
+					t.Errorf("next days[%d]: got %s, want %s", i, got, tt.wantNextDays[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitEmpty(t *testing.T) {
+	today, nextDays := Split(nil, time.Now())
+	if len(today) != 0 || len(nextDays) != 0 {
+		t.Errorf("got %d today slots and %d next days for empty forecast", len(today), len(nextDays))
+	}
+}
+
+func TestDaily(t *testing.T) {
+	orsk := time.FixedZone("", 5*60*60)
+	at := func(hour int) time.Time {
+		return time.Date(2025, 10, 21, hour, 0, 0, 0, orsk)
+	}
+
+	forecast := []models.Weather{
+		{Date: at(2), Description: "ясно", Temp: -3.4, Speed: 1},
+		{Date: at(5), Description: "ясно", Temp: -4.2, Speed: 2},
+		{Date: at(8), Description: "небольшой дождь", Temp: 1.5, Speed: 6.5, Precipitation: 0.4},
+		{Date: at(11), Description: "небольшой дождь", Temp: 5.1, Speed: 4, Precipitation: 1.1},
+		{Date: at(14), Description: "небольшой дождь", Temp: 6.8, Speed: 3, Precipitation: 0.5},
+		{Date: at(17), Description: "пасмурно", Temp: 3, Speed: 2},
+		{Date: at(23).Add(3 * time.Hour), Description: "ясно", Temp: -1, Speed: 1},
+	}
+
+	days := Daily(forecast)
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2", len(days))
+	}
+
+	day := days[0]
+	if got := day.Date.Format(time.DateTime); got != "2025-10-21 00:00:00" {
+		t.Errorf("date: got %s", got)
+	}
+	if day.MinTemp != -4.2 || day.MaxTemp != 6.8 {
+		t.Errorf("min/max: got %.1f/%.1f, want -4.2/6.8", day.MinTemp, day.MaxTemp)
+	}
+	if day.Description != "небольшой дождь" {
+		t.Errorf("dominant condition: got %q", day.Description)
+	}
+	if day.Precipitation < 1.99 || day.Precipitation > 2.01 {
+		t.Errorf("precipitation: got %.2f, want 2.0", day.Precipitation)
+	}
+	if day.MaxSpeed != 6.5 {
+		t.Errorf("max wind: got %.1f, want 6.5", day.MaxSpeed)
+	}
+}
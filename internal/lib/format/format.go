@@ -3,108 +3,85 @@ package format
 import (
 	"fmt"
 	"math"
-	"strings"
-	"time"
 
+	"github.com/m1al04949/weatherbot/internal/lib/condition"
 	"github.com/m1al04949/weatherbot/internal/models"
 )
 
-const (
-	DateTimeFormat = "2006-01-02 15:04:05"
-	DateFormat     = "2006-01-02"
-)
-
-// Help function: formating message
-func FormatWeatherMessage(item models.Weather) string {
-
-	var (
-		first  string
-		result string
-	)
+var weekdaysRu = []string{
+	"Вс",
+	"Пн",
+	"Вт",
+	"Ср",
+	"Чт",
+	"Пт",
+	"Сб",
+}
 
-	WeekdaysRu := []string{
-		"Вс",
-		"Пн",
-		"Вт",
-		"Ср",
-		"Чт",
-		"Пт",
-		"Сб",
-	}
+// Emoji of current or 3-hour slot weather, night is taken from icon
+func getWeatherEmoji(weather models.Weather) string {
+	return condition.Emoji(weather.Code, condition.IsNight(weather.Icon))
+}
 
-	today := time.Now().Format(DateFormat)
+// Emoji of day in forecast
+func getDayEmoji(day models.DailyForecast) string {
+	return condition.Emoji(day.Code, false)
+}
 
-	itemTime, _ := time.Parse("2006-01-02 15:04:05", item.Date)
+// Current temperature against the middle of the day range
+func compareTemp(now float64, day models.DailyForecast) string {
+	diff := int(math.Round(now - (day.MinTemp+day.MaxTemp)/2))
 
 	switch {
-	case item.Date == "":
-		result = fmt.Sprintf("Сегодня температура %d°C %s\n%s %s\nветер %d м/с %s",
-			int(math.Round(item.Temp)), getTempEmoji(int(math.Round(item.Temp))),
-			item.Description, getWeatherEmoji(item.Description),
-			int(math.Round(item.Speed)), getWindEmoji(int(math.Round(item.Speed))),
-		)
-		return result
-	case itemTime.Format(DateFormat) == today:
-		first = itemTime.Format("15:04")
+	case diff > 0:
+		return fmt.Sprintf("на %d° теплее", diff)
+	case diff < 0:
+		return fmt.Sprintf("на %d° холоднее", -diff)
 	default:
-		first = itemTime.Format("02.01")
+		return "как и тогда"
 	}
+}
 
-	result = "├─────────────────────────┤\n"
-	result += fmt.Sprintf("│  %5s         %3d°C %-8s    %-8s %-3dм/с \n",
-		first,
-		int(math.Round(item.Temp)),
-		getTempEmoji(int(math.Round(item.Temp))),
-		getWeatherEmoji(item.Description),
-		int(math.Round(item.Speed)),
-	)
-	result += fmt.Sprintf("│ %5s              %20s  \n",
-		WeekdaysRu[int(itemTime.Weekday())],
-		item.Description,
-	)
+var sparks = []rune("▁▂▃▄▅▆▇█")
 
-	return result
-}
+// Mean temperatures of days as a line of bars
+func sparkline(days []models.DayTemperature) string {
+	if len(days) == 0 {
+		return ""
+	}
 
-func getWeatherEmoji(weather string) string {
-	switch {
-	case strings.Contains(weather, "ясно"):
-		return "☀️"
-	case strings.Contains(weather, "снег"):
-		return "🌨️"
-	case strings.Contains(weather, "облачно с прояснениями"):
-		return "🌤️"
-	case strings.Contains(weather, "пасмурно"):
-		return "☁️"
-	case strings.Contains(weather, "небольшой дождь"):
-		return "☔"
-	case strings.Contains(weather, "дождь"):
-		return "🌧️"
-	case strings.Contains(weather, "гроза"):
-		return "⛈️"
-	case strings.Contains(weather, "облачность"):
-		return "⛅"
-	default:
-		return "🌈"
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, day := range days {
+		low = math.Min(low, day.MeanTemp)
+		high = math.Max(high, day.MeanTemp)
+	}
+
+	line := make([]rune, 0, len(days))
+	for _, day := range days {
+		i := len(sparks) / 2
+		if high > low {
+			i = int(math.Round((day.MeanTemp - low) / (high - low) * float64(len(sparks)-1)))
+		}
+		line = append(line, sparks[i])
 	}
+
+	return string(line)
 }
 
 func getTempEmoji(temp int) string {
 	switch {
 	case temp > 25:
 		return "🔥"
-	case temp > 14 && temp < 26:
+	case temp > 14:
 		return "😊"
-	case temp > 9 && temp < 15:
+	case temp > 9:
 		return "😐"
-	case temp > 0 && temp < 10:
+	case temp > 0:
 		return "🥺"
-	case temp < 1:
+	case temp > -10:
 		return "❄️"
-	case temp < -9:
-		return "🧊"
 	default:
-		return "😊"
+		return "🧊"
 	}
 }
 
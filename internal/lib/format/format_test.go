@@ -0,0 +1,74 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+func TestGetTempEmoji(t *testing.T) {
+	tests := []struct {
+		temp int
+		want string
+	}{
+		{40, "🔥"},
+		{26, "🔥"},
+		{25, "😊"},
+		{15, "😊"},
+		{14, "😐"},
+		{10, "😐"},
+		{9, "🥺"},
+		{1, "🥺"},
+		{0, "❄️"},
+		{-9, "❄️"},
+		{-10, "🧊"},
+		{-30, "🧊"},
+	}
+
+	for _, tt := range tests {
+		if got := getTempEmoji(tt.temp); got != tt.want {
+			t.Errorf("getTempEmoji(%d) = %s, want %s", tt.temp, got, tt.want)
+		}
+	}
+}
+
+func TestGetWeatherEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		weather models.Weather
+		want    string
+	}{
+		{"clear day", models.Weather{Code: 800, Icon: "01d"}, "☀️"},
+		{"clear night", models.Weather{Code: 800, Icon: "01n"}, "🌙"},
+		// Description doesn't matter, any language works
+		{"english", models.Weather{Code: 802, Icon: "03d", Description: "scattered clouds"}, "⛅"},
+		{"no icon is day", models.Weather{Code: 801}, "🌤️"},
+		{"unknown", models.Weather{Description: "ясно"}, "🌈"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getWeatherEmoji(tt.weather); got != tt.want {
+				t.Errorf("getWeatherEmoji() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{html: "<b>Москва</b>", want: "Москва"},
+		{html: `<a href="https://t.me">ссылка</a> и <i>курсив</i>`, want: "ссылка и курсив"},
+		{html: "1 &lt; 2 &amp;&amp; <code>x &gt; y</code>", want: "1 < 2 && x > y"},
+		{html: "без разметки", want: "без разметки"},
+	}
+
+	for _, tt := range tests {
+		if got := PlainText(tt.html); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package format
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+// Template names, the same names are looked up in override directory
+const (
+	TemplateWeather  = "weather.tmpl"
+	TemplateForecast = "forecast.tmpl"
+	TemplateHourly   = "hourly.tmpl"
+	TemplateHistory  = "history.tmpl"
+	TemplateTrend    = "trend.tmpl"
+)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// Data of weather templates
+type Current struct {
+	Name    string
+	Weather models.Weather
+}
+
+type Forecast struct {
+	Name  string
+	Today []models.Weather
+	Days  []models.DailyForecast
+}
+
+type Hourly struct {
+	Name  string
+	Items []models.Weather
+}
+
+// Observed day compared with current weather, Today is optional
+type History struct {
+	Name  string
+	Day   models.DailyForecast
+	Today *models.Weather
+}
+
+// Recorded temperatures by day
+type Trend struct {
+	Name string
+	Days []models.DayTemperature
+}
+
+// Templates render messages for HTML parse mode
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// Embedded templates, files with the same names in dir replace them
+func New(dir string) (*Templates, error) {
+	op := "format.new"
+
+	names, err := fs.Glob(embedded, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t := &Templates{templates: make(map[string]*template.Template, len(names))}
+	for _, name := range names {
+		name = filepath.Base(name)
+
+		text, err := fs.ReadFile(embedded, "templates/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if dir != "" {
+			override, err := os.ReadFile(filepath.Join(dir, name))
+			switch {
+			case err == nil:
+				text = override
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+		t.templates[name] = tmpl
+	}
+
+	return t, nil
+}
+
+func (t *Templates) Render(name string, data any) (string, error) {
+	op := "format.render"
+
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return "", fmt.Errorf("%s: unknown template %s", op, name)
+	}
+
+	var text strings.Builder
+	if err := tmpl.Execute(&text, data); err != nil {
+		return "", fmt.Errorf("%s: %s: %w", op, name, err)
+	}
+
+	return strings.TrimSpace(text.String()), nil
+}
+
+// Functions available in templates
+var funcs = template.FuncMap{
+	"round": func(v float64) int {
+		return int(math.Round(v))
+	},
+	"weekday": func(t time.Time) string {
+		return weekdaysRu[int(t.Weekday())]
+	},
+	"weatherEmoji": getWeatherEmoji,
+	"dayEmoji":     getDayEmoji,
+	"tempEmoji": func(temp float64) string {
+		return getTempEmoji(int(math.Round(temp)))
+	},
+	"windEmoji": func(speed float64) string {
+		return getWindEmoji(int(math.Round(speed)))
+	},
+	"compare":   compareTemp,
+	"sparkline": sparkline,
+}
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// Text of HTML message as the user sees it, for terminal output and long messages
+func PlainText(text string) string {
+	return html.UnescapeString(tags.ReplaceAllString(text, ""))
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "expvar"
+
+// Counters published by expvar
+var (
+	Updates     = expvar.NewMap("updates")      // by route
+	UpdatesTime = expvar.NewMap("updates_ms")   // total handling time by route
+	Panics      = expvar.NewInt("panics")       // recovered in handlers
+	RateLimited = expvar.NewInt("rate_limited") // dropped by rate limiter
+	CacheHits   = expvar.NewInt("cache_hits")   // current weather answered from cache
+	CacheMisses = expvar.NewInt("cache_misses") // current weather requested from provider
+
+	Delivered      = expvar.NewInt("outbox_delivered") // messages sent from outbound queue
+	DeliveryFailed = expvar.NewInt("outbox_failed")    // messages dropped from outbound queue
+
+	ForecastTempMAE = expvar.NewMap("forecast_temp_mae") // by provider and lead time, °C
+	ForecastSamples = expvar.NewMap("forecast_samples")  // by provider and lead time
+)
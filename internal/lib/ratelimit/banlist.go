@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Temporary bans of users
+type BanList struct {
+	mu   sync.Mutex
+	bans map[int64]time.Time
+}
+
+func NewBanList() *BanList {
+	return &BanList{bans: make(map[int64]time.Time)}
+}
+
+func (b *BanList) Ban(userID int64, duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bans[userID] = time.Now().Add(duration)
+}
+
+// Returns false if user is not banned
+func (b *BanList) Unban(userID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.bans[userID]
+	delete(b.bans, userID)
+
+	return ok
+}
+
+func (b *BanList) Banned(userID int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until, ok := b.bans[userID]
+	if ok && time.Now().After(until) {
+		delete(b.bans, userID)
+		return false
+	}
+
+	return ok
+}
+
+// Active bans with expiration time
+func (b *BanList) List() map[int64]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	list := make(map[int64]time.Time, len(b.bans))
+	for userID, until := range b.bans {
+		if now.After(until) {
+			delete(b.bans, userID)
+			continue
+		}
+		list[userID] = until
+	}
+
+	return list
+}
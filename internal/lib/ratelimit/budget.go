@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Daily budget of API calls, resets at UTC midnight
+type Budget struct {
+	mu    sync.Mutex
+	limit int
+	spent int
+	day   string
+}
+
+// Zero limit means unlimited budget
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Spend n calls if budget allows
+func (b *Budget) Spend(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+	if b.limit > 0 && b.spent+n > b.limit {
+		return false
+	}
+	b.spent += n
+
+	return true
+}
+
+// Record n calls made regardless of budget
+func (b *Budget) Add(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+	b.spent += n
+}
+
+// Calls spent today
+func (b *Budget) Spent() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+	return b.spent
+}
+
+func (b *Budget) Limit() int {
+	return b.limit
+}
+
+func (b *Budget) reset() {
+	if today := time.Now().UTC().Format(time.DateOnly); today != b.day {
+		b.day = today
+		b.spent = 0
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import "errors"
+
+var (
+	ErrBanned      = errors.New("user is banned")
+	ErrTooOften    = errors.New("too many requests of user")
+	ErrOverloaded  = errors.New("too many requests of all users")
+	ErrBudgetSpent = errors.New("daily API budget is spent")
+)
+
+// Guard of weather provider calls: bans, per-user and global limits, daily budget
+type Guard struct {
+	users  *Limiter
+	global *Limiter
+	budget *Budget
+	bans   *BanList
+}
+
+func NewGuard(users, global *Limiter, budget *Budget, bans *BanList) *Guard {
+	return &Guard{
+		users:  users,
+		global: global,
+		budget: budget,
+		bans:   bans,
+	}
+}
+
+// Check user may make n provider calls, nothing is spent if any limit denies them
+func (g *Guard) Allow(userID int64, calls int) error {
+	if g.bans.Banned(userID) {
+		return ErrBanned
+	}
+	if !g.users.AllowN(userID, calls) {
+		return ErrTooOften
+	}
+	if !g.global.AllowN(0, calls) {
+		g.users.Cancel(userID, calls)
+		return ErrOverloaded
+	}
+	if !g.budget.Spend(calls) {
+		g.global.Cancel(0, calls)
+		g.users.Cancel(userID, calls)
+		return ErrBudgetSpent
+	}
+
+	return nil
+}
+
+func (g *Guard) Budget() *Budget {
+	return g.budget
+}
+
+func (g *Guard) Bans() *BanList {
+	return g.bans
+}
@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	// Practically no refill during the test
+	l := New(0.001, 3)
+
+	if !l.AllowN(1, 2) || !l.Allow(1) {
+		t.Fatal("burst must be allowed")
+	}
+	if l.Allow(1) {
+		t.Error("empty bucket must deny")
+	}
+	if !l.AllowN(2, 3) {
+		t.Error("keys must have own buckets")
+	}
+	if l.AllowN(3, 4) {
+		t.Error("more than burst must be denied")
+	}
+
+	// Cancel doesn't overflow the bucket
+	l.Cancel(1, 2)
+	l.Cancel(3, 10)
+	if !l.AllowN(1, 2) || l.Allow(1) {
+		t.Error("cancelled tokens must be returned once")
+	}
+	if l.AllowN(3, 4) || !l.AllowN(3, 3) {
+		t.Error("bucket must be limited by burst")
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := New(1000, 1)
+
+	if !l.Allow(1) {
+		t.Fatal("first token must be allowed")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !l.Allow(1) {
+		t.Error("token must be refilled")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(5)
+
+	if !b.Spend(3) || b.Spend(3) || !b.Spend(2) {
+		t.Error("budget must allow calls up to the limit only")
+	}
+	b.Add(2)
+	if b.Spent() != 7 {
+		t.Errorf("spent: got %d, want 7", b.Spent())
+	}
+
+	// New day starts with full budget
+	b.day = "2000-01-01"
+	if b.Spent() != 0 || !b.Spend(5) {
+		t.Error("budget must reset on the next day")
+	}
+
+	unlimited := NewBudget(0)
+	if !unlimited.Spend(1_000_000) {
+		t.Error("zero limit must be unlimited")
+	}
+}
+
+func TestBanList(t *testing.T) {
+	b := NewBanList()
+
+	b.Ban(1, time.Hour)
+	b.Ban(2, -time.Second)
+
+	if !b.Banned(1) || b.Banned(2) || b.Banned(3) {
+		t.Error("only active ban must count")
+	}
+	if list := b.List(); len(list) != 1 || list[1].IsZero() {
+		t.Errorf("list: got %v", list)
+	}
+	if !b.Unban(1) || b.Unban(1) || b.Banned(1) {
+		t.Error("unban must remove the ban once")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		guard  func() *Guard
+		userID int64
+		want   error
+	}{
+		{
+			name: "allowed",
+			guard: func() *Guard {
+				return NewGuard(New(0.001, 2), New(0.001, 2), NewBudget(2), NewBanList())
+			},
+			userID: 1,
+		},
+		{
+			name: "banned",
+			guard: func() *Guard {
+				bans := NewBanList()
+				bans.Ban(1, time.Hour)
+				return NewGuard(New(0.001, 2), New(0.001, 2), NewBudget(2), bans)
+			},
+			userID: 1,
+			want:   ErrBanned,
+		},
+		{
+			name: "user limit",
+			guard: func() *Guard {
+				return NewGuard(New(0.001, 1), New(0.001, 2), NewBudget(2), NewBanList())
+			},
+			userID: 1,
+			want:   ErrTooOften,
+		},
+		{
+			name: "global limit",
+			guard: func() *Guard {
+				return NewGuard(New(0.001, 2), New(0.001, 1), NewBudget(2), NewBanList())
+			},
+			userID: 1,
+			want:   ErrOverloaded,
+		},
+		{
+			name: "budget",
+			guard: func() *Guard {
+				return NewGuard(New(0.001, 2), New(0.001, 2), NewBudget(1), NewBanList())
+			},
+			userID: 1,
+			want:   ErrBudgetSpent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.guard().Allow(tt.userID, 2); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardDenialSpendsNothing(t *testing.T) {
+	users, global, budget := New(0.001, 4), New(0.001, 4), NewBudget(2)
+	g := NewGuard(users, global, budget, NewBanList())
+
+	if err := g.Allow(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Budget is spent, denied calls must not drain the buckets
+	for range 3 {
+		if err := g.Allow(1, 1); !errors.Is(err, ErrBudgetSpent) {
+			t.Fatalf("got %v, want %v", err, ErrBudgetSpent)
+		}
+	}
+	if !users.AllowN(1, 2) || !global.AllowN(0, 2) {
+		t.Error("tokens of denied calls are lost")
+	}
+
+	// Global limit denial returns tokens of user
+	users, global = New(0.001, 2), New(0.001, 1)
+	g = NewGuard(users, global, NewBudget(0), NewBanList())
+	if err := g.Allow(1, 2); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("got %v, want %v", err, ErrOverloaded)
+	}
+	if !users.AllowN(1, 2) {
+		t.Error("user tokens of denied call are lost")
+	}
+	if budget := g.Budget().Spent(); budget != 0 {
+		t.Errorf("budget spent: got %d, want 0", budget)
+	}
+}
@@ -0,0 +1,414 @@
+// Package telegramtest is a fake Telegram Bot API for end-to-end tests:
+// scenarios inject updates from users and check requests sent by the bot
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	Token       = "123456:TEST"
+	BotID       = 123456
+	BotUserName = "test_weatherbot"
+
+	waitTimeout = 5 * time.Second
+	pollTimeout = time.Second // longer polls of getUpdates are cut
+)
+
+// Request of the bot other than getMe and getUpdates
+type Request struct {
+	Method    string
+	Params    url.Values
+	Files     map[string][]byte
+	MessageID int // of sent or edited message
+}
+
+type Server struct {
+	t      testing.TB
+	server *httptest.Server
+
+	mu          sync.Mutex
+	updates     []tgbotapi.Update
+	lastUpdate  int
+	lastMessage int
+	notify      chan struct{}
+	closed      chan struct{}
+
+	requests chan Request
+}
+
+// Start server, it's closed on test cleanup
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		t:        t,
+		notify:   make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+		requests: make(chan Request, 100),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(func() {
+		// Release long polling before waiting for requests in flight
+		close(s.closed)
+		s.server.Close()
+	})
+
+	return s
+}
+
+// Bot connected to the server, it stops receiving updates on test cleanup
+func (s *Server) Bot() *tgbotapi.BotAPI {
+	s.t.Helper()
+
+	bot, err := tgbotapi.NewBotAPIWithClient(Token, s.server.URL+"/bot%s/%s", s.server.Client())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(bot.StopReceivingUpdates)
+
+	return bot
+}
+
+// Message of user in private chat with the same ID, commands are marked as in Telegram
+func (s *Server) SendText(userID int64, text string) tgbotapi.Update {
+	return s.SendGroupText(userID, userID, text)
+}
+
+// Text message of user in chat, negative chat ID is a group
+func (s *Server) SendGroupText(chatID, userID int64, text string) tgbotapi.Update {
+	s.mu.Lock()
+	s.lastMessage++
+	msg := &tgbotapi.Message{
+		MessageID: s.lastMessage,
+		From:      user(userID),
+		Chat:      chat(chatID),
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	s.mu.Unlock()
+
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len([]rune(command))}}
+	}
+
+	return s.push(tgbotapi.Update{Message: msg})
+}
+
+// Press of inline button with data under the message sent by the bot
+func (s *Server) Press(userID int64, req Request, data string) tgbotapi.Update {
+	return s.push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   strconv.Itoa(req.MessageID) + ":" + data,
+		From: user(userID),
+		Message: &tgbotapi.Message{
+			MessageID: req.MessageID,
+			From:      &tgbotapi.User{ID: BotID, IsBot: true, UserName: BotUserName},
+			Chat:      chat(userID),
+			Text:      req.Text(),
+		},
+		Data: data,
+	}})
+}
+
+// Inline query of user typed after the bot's name
+func (s *Server) Inline(userID int64, query string) tgbotapi.Update {
+	s.mu.Lock()
+	s.lastMessage++
+	id := strconv.Itoa(s.lastMessage)
+	s.mu.Unlock()
+
+	return s.push(tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{
+		ID:    id,
+		From:  user(userID),
+		Query: query,
+	}})
+}
+
+// Next request of the bot, test fails if there is none
+func (s *Server) Next() Request {
+	s.t.Helper()
+
+	select {
+	case req := <-s.requests:
+		return req
+	case <-time.After(waitTimeout):
+		s.t.Fatal("no request from the bot")
+		return Request{}
+	}
+}
+
+// Next request with the method, test fails on any other
+func (s *Server) Expect(method string) Request {
+	s.t.Helper()
+
+	req := s.Next()
+	if req.Method != method {
+		s.t.Fatalf("got %s %v, want %s", req.Method, req.Params, method)
+	}
+
+	return req
+}
+
+// Test fails if the bot sends anything during d
+func (s *Server) Silent(d time.Duration) {
+	s.t.Helper()
+
+	select {
+	case req := <-s.requests:
+		s.t.Fatalf("unexpected request %s %v", req.Method, req.Params)
+	case <-time.After(d):
+	}
+}
+
+func (s *Server) push(update tgbotapi.Update) tgbotapi.Update {
+	s.mu.Lock()
+	s.lastUpdate++
+	update.UpdateID = s.lastUpdate
+	s.updates = append(s.updates, update)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return update
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	req, err := parseRequest(r, method)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Weather", UserName: BotUserName})
+		return
+	case "getUpdates":
+		writeResult(w, s.poll(r, req.Params))
+		return
+	}
+
+	chatID := req.ChatID()
+	switch method {
+	case "sendMessage", "sendPhoto", "sendDocument":
+		s.mu.Lock()
+		s.lastMessage++
+		req.MessageID = s.lastMessage
+		s.mu.Unlock()
+	case "editMessageText":
+		req.MessageID, _ = strconv.Atoi(req.Params.Get("message_id"))
+	case "answerCallbackQuery", "answerInlineQuery", "sendChatAction", "setMyCommands":
+	default:
+		s.t.Errorf("telegramtest: method %s is not implemented", method)
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.requests <- req
+
+	if req.MessageID == 0 {
+		writeResult(w, true)
+		return
+	}
+	writeResult(w, tgbotapi.Message{
+		MessageID: req.MessageID,
+		From:      &tgbotapi.User{ID: BotID, IsBot: true, UserName: BotUserName},
+		Chat:      chat(chatID),
+		Date:      int(time.Now().Unix()),
+		Text:      req.Params.Get("text"),
+		Caption:   req.Params.Get("caption"),
+	})
+}
+
+// Updates from offset, waits for new ones like long polling
+func (s *Server) poll(r *http.Request, params url.Values) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(params.Get("offset"))
+	timeout, _ := strconv.Atoi(params.Get("timeout"))
+	deadline := time.After(min(time.Duration(timeout)*time.Second, pollTimeout))
+
+	for {
+		s.mu.Lock()
+		var updates []tgbotapi.Update
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			return updates
+		}
+
+		select {
+		case <-s.notify:
+		case <-deadline:
+			return []tgbotapi.Update{}
+		case <-s.closed:
+			return []tgbotapi.Update{}
+		case <-r.Context().Done():
+			return []tgbotapi.Update{}
+		}
+	}
+}
+
+// Form of plain requests, fields and files of uploads
+func parseRequest(r *http.Request, method string) (Request, error) {
+	req := Request{Method: method, Files: map[string][]byte{}}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return req, err
+		}
+		req.Params = url.Values(r.MultipartForm.Value)
+		for field, headers := range r.MultipartForm.File {
+			file, err := headers[0].Open()
+			if err != nil {
+				return req, err
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return req, err
+			}
+			req.Files[field] = data
+		}
+		return req, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+	req.Params = r.PostForm
+
+	return req, nil
+}
+
+// Text of message or caption of photo
+func (r Request) Text() string {
+	if text := r.Params.Get("text"); text != "" {
+		return text
+	}
+
+	return r.Params.Get("caption")
+}
+
+func (r Request) ChatID() int64 {
+	id, _ := strconv.ParseInt(r.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// Texts of reply or inline keyboard buttons, row by row
+func (r Request) Buttons() []string {
+	var markup struct {
+		Keyboard       [][]tgbotapi.KeyboardButton       `json:"keyboard"`
+		InlineKeyboard [][]tgbotapi.InlineKeyboardButton `json:"inline_keyboard"`
+	}
+	if err := json.Unmarshal([]byte(r.Params.Get("reply_markup")), &markup); err != nil {
+		return nil
+	}
+
+	var buttons []string
+	for _, row := range markup.Keyboard {
+		for _, button := range row {
+			buttons = append(buttons, button.Text)
+		}
+	}
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			buttons = append(buttons, button.Text)
+		}
+	}
+
+	return buttons
+}
+
+// Titles of inline query results
+func (r Request) Results() []string {
+	var results []struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(r.Params.Get("results")), &results); err != nil {
+		return nil
+	}
+
+	titles := make([]string, 0, len(results))
+	for _, result := range results {
+		titles = append(titles, result.Title)
+	}
+
+	return titles
+}
+
+// Callback data of the inline button with text
+func (r Request) Callback(text string) (string, bool) {
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(r.Params.Get("reply_markup")), &markup); err != nil {
+		return "", false
+	}
+
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text && button.CallbackData != nil {
+				return *button.CallbackData, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("%s %s", r.Method, r.Text())
+}
+
+func user(id int64) *tgbotapi.User {
+	return &tgbotapi.User{ID: id, FirstName: "User" + strconv.FormatInt(id, 10), LanguageCode: "ru"}
+}
+
+func chat(id int64) *tgbotapi.Chat {
+	if id < 0 {
+		return &tgbotapi.Chat{ID: id, Type: "group", Title: "Group"}
+	}
+
+	return &tgbotapi.Chat{ID: id, Type: "private"}
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: status, Description: description})
+}
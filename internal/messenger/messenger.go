@@ -0,0 +1,404 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/format"
+)
+
+const (
+	// Telegram limit of message text length in UTF-16 code units
+	TextLimit = 4096
+
+	retries      = 3
+	retryBackoff = 500 * time.Millisecond // doubled after every failed attempt
+	maxRetryWait = 10 * time.Second       // longer flood control waits are not worth it for replies
+)
+
+type Bot interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// Messenger sends replies to Telegram: retries transient failures,
+// splits long texts and logs every failure.
+// Requests are never delayed in the caller: the ones that must wait before retry
+// are retried in background, later requests of the same chat queue behind them
+type Messenger struct {
+	bot Bot
+	log *slog.Logger
+
+	ctx    context.Context // cancelled by Close
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	delayed map[int64][]*request // by chat, zero for requests without chat
+}
+
+// Request with its retry state
+type request struct {
+	c       tgbotapi.Chattable
+	do      func(c tgbotapi.Chattable) error
+	attempt int           // failed attempts
+	backoff time.Duration // before next retry of network failure
+	wait    time.Duration // before next attempt
+}
+
+func New(bot Bot, log *slog.Logger) *Messenger {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Messenger{
+		bot:     bot,
+		log:     log,
+		ctx:     ctx,
+		cancel:  cancel,
+		delayed: make(map[int64][]*request),
+	}
+}
+
+// Stop waiting retries, delayed requests are dropped
+func (m *Messenger) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Send message, text over the limit is sent as several messages,
+// the last sent message is returned. It's zero if the message is delayed
+func (m *Messenger) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, ok := c.(tgbotapi.MessageConfig)
+	if !ok || textLen(msg.Text) <= TextLimit {
+		return m.send(c)
+	}
+
+	// Parts can't be cut between tags, long text goes without formatting
+	if msg.ParseMode == tgbotapi.ModeHTML {
+		msg.Text = format.PlainText(msg.Text)
+		msg.ParseMode = ""
+	}
+
+	var (
+		sent tgbotapi.Message
+		err  error
+	)
+	parts := Split(msg.Text, TextLimit)
+	for i, part := range parts {
+		chunk := msg
+		chunk.Text = part
+		// Reply to the first part, buttons under the last one
+		if i > 0 {
+			chunk.ReplyToMessageID = 0
+		}
+		if i < len(parts)-1 {
+			chunk.ReplyMarkup = nil
+		}
+
+		if sent, err = m.send(chunk); err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// Request without message in result, e.g. callback answers and edits,
+// response is nil if the request is delayed
+func (m *Messenger) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	err := m.run(c, func(c tgbotapi.Chattable) error {
+		var err error
+		resp, err = m.bot.Request(c)
+		return err
+	})
+
+	return resp, err
+}
+
+func (m *Messenger) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var sent tgbotapi.Message
+	err := m.run(c, func(c tgbotapi.Chattable) error {
+		var err error
+		sent, err = m.bot.Send(c)
+		return err
+	})
+
+	return sent, err
+}
+
+// Call do until success or permanent error, retries after a wait go to background
+func (m *Messenger) run(c tgbotapi.Chattable, do func(c tgbotapi.Chattable) error) error {
+	r := &request{c: c, do: do, backoff: retryBackoff}
+	chatID := chatOf(c)
+
+	if m.queue(chatID, r, false) {
+		return nil
+	}
+
+	for {
+		done, err := m.try(r)
+		if done {
+			return err
+		}
+		if r.wait > 0 {
+			m.queue(chatID, r, true)
+			return nil
+		}
+	}
+}
+
+// Put request behind delayed requests of chat, if there are none it's queued
+// only when force is set. Queue of chat is drained by its own goroutine
+func (m *Messenger) queue(chatID int64, r *request, force bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue, ok := m.delayed[chatID]
+	if !ok && !force {
+		return false
+	}
+
+	m.delayed[chatID] = append(queue, r)
+	if !ok {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.drain(chatID)
+		}()
+	}
+
+	return true
+}
+
+// Run delayed requests of chat in order until the queue is empty or messenger is closed
+func (m *Messenger) drain(chatID int64) {
+	for {
+		m.mu.Lock()
+		queue := m.delayed[chatID]
+		if len(queue) == 0 {
+			delete(m.delayed, chatID)
+			m.mu.Unlock()
+			return
+		}
+		r := queue[0]
+		m.delayed[chatID] = queue[1:]
+		m.mu.Unlock()
+
+		for {
+			if r.wait > 0 && !m.sleep(r.wait) {
+				m.drop(chatID)
+				return
+			}
+			if done, _ := m.try(r); done {
+				break
+			}
+		}
+	}
+}
+
+// Drop delayed requests of chat on close, the current one is out of queue already
+func (m *Messenger) drop(chatID int64) {
+	m.mu.Lock()
+	n := len(m.delayed[chatID]) + 1
+	delete(m.delayed, chatID)
+	m.mu.Unlock()
+
+	m.log.Error("delayed telegram requests dropped", slog.Int64("chat_id", chatID), slog.Int("count", n))
+}
+
+// Wait unless messenger is closed
+func (m *Messenger) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-m.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// One attempt of request, it's done on success, permanent error or when retries are over,
+// else request is prepared for the next attempt
+func (m *Messenger) try(r *request) (bool, error) {
+	err := r.do(r.c)
+	if err == nil {
+		return true, nil
+	}
+	r.attempt++
+
+	var (
+		apiErr *tgbotapi.Error
+		netErr *url.Error
+		code   int
+	)
+	if errors.As(err, &apiErr) {
+		code = codeOf(apiErr)
+	}
+
+	attrs := []any{
+		slog.String("request", fmt.Sprintf("%T", r.c)),
+		slog.Int("attempt", r.attempt),
+		slog.String("error", err.Error()),
+	}
+	if chatID := chatOf(r.c); chatID != 0 {
+		attrs = append(attrs, slog.Int64("chat_id", chatID))
+	}
+	if apiErr != nil {
+		attrs = append(attrs, slog.Int("code", code))
+	}
+	m.log.Error("telegram request failed", attrs...)
+
+	if r.attempt > retries {
+		return true, err
+	}
+
+	switch {
+	case apiErr == nil && errors.As(err, &netErr), code >= http.StatusInternalServerError:
+		// Network failure or Telegram is unavailable
+		r.wait = r.backoff
+		r.backoff *= 2
+
+	case apiErr == nil:
+		// Telegram may have accepted the request, e.g. its response isn't decoded
+		return true, err
+
+	case code == http.StatusTooManyRequests:
+		r.wait = time.Duration(apiErr.RetryAfter) * time.Second
+		if r.wait > maxRetryWait {
+			return true, err
+		}
+
+	case code == http.StatusBadRequest && strings.Contains(apiErr.Message, "replied"):
+		// Original message was deleted, send without reply
+		without, ok := withoutReply(r.c)
+		if !ok {
+			return true, err
+		}
+		r.c = without
+		r.wait = 0
+
+	default:
+		return true, err
+	}
+
+	return false, err
+}
+
+// Split text by lines into parts no longer than limit,
+// too long lines are cut
+func Split(text string, limit int) []string {
+	var (
+		parts []string
+		part  strings.Builder
+		size  int
+	)
+	flush := func() {
+		if part.Len() > 0 {
+			parts = append(parts, part.String())
+			part.Reset()
+			size = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineSize := textLen(line)
+		if size+lineSize <= limit {
+			part.WriteString(line)
+			size += lineSize
+			continue
+		}
+
+		flush()
+		for _, r := range line {
+			runeSize := utf16.RuneLen(r)
+			if runeSize < 0 {
+				runeSize = 1
+			}
+			if size+runeSize > limit {
+				flush()
+			}
+			part.WriteRune(r)
+			size += runeSize
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// Length as Telegram counts it
+func textLen(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// Status of API error, uploads of files have no code in error and it's taken
+// from description, e.g. "Too Many Requests: retry after 5"
+func codeOf(err *tgbotapi.Error) int {
+	switch {
+	case err.Code != 0:
+		return err.Code
+	case err.RetryAfter > 0:
+		return http.StatusTooManyRequests
+	}
+
+	for code := http.StatusBadRequest; code <= http.StatusNetworkAuthenticationRequired; code++ {
+		if status := http.StatusText(code); status != "" && strings.HasPrefix(err.Message, status) {
+			return code
+		}
+	}
+
+	return 0
+}
+
+func withoutReply(c tgbotapi.Chattable) (tgbotapi.Chattable, bool) {
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		if config.ReplyToMessageID == 0 {
+			return c, false
+		}
+		config.ReplyToMessageID = 0
+		return config, true
+	case tgbotapi.PhotoConfig:
+		if config.ReplyToMessageID == 0 {
+			return c, false
+		}
+		config.ReplyToMessageID = 0
+		return config, true
+	case tgbotapi.DocumentConfig:
+		if config.ReplyToMessageID == 0 {
+			return c, false
+		}
+		config.ReplyToMessageID = 0
+		return config, true
+	}
+
+	return c, false
+}
+
+func chatOf(c tgbotapi.Chattable) int64 {
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		return config.ChatID
+	case tgbotapi.PhotoConfig:
+		return config.ChatID
+	case tgbotapi.DocumentConfig:
+		return config.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return config.ChatID
+	case tgbotapi.ChatActionConfig:
+		return config.ChatID
+	}
+
+	return 0
+}
@@ -0,0 +1,389 @@
+package messenger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "empty", text: "", limit: 5, want: nil},
+		{name: "exactly limit", text: "abcde", limit: 5, want: []string{"abcde"}},
+		{name: "over limit", text: "abcdef", limit: 5, want: []string{"abcde", "f"}},
+		{name: "by lines", text: "ab\ncd\nef", limit: 6, want: []string{"ab\ncd\n", "ef"}},
+		{name: "long line is cut", text: "ab\ncdefgh\ni", limit: 4, want: []string{"ab\n", "cdef", "gh\ni"}},
+		{name: "cyrillic is one unit", text: "приветмир", limit: 6, want: []string{"привет", "мир"}},
+		{name: "surrogate pair is not cut", text: "a😀b", limit: 2, want: []string{"a", "😀", "b"}},
+		{name: "surrogate pairs at limit", text: "😀😀😀", limit: 4, want: []string{"😀😀", "😀"}},
+		{name: "emoji line", text: "😀\n😀", limit: 3, want: []string{"😀\n", "😀"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, tt.limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if strings.Join(got, "") != tt.text {
+				t.Errorf("parts %q lose text", got)
+			}
+			for _, part := range got {
+				if textLen(part) > tt.limit {
+					t.Errorf("part %q is over limit", part)
+				}
+			}
+		})
+	}
+}
+
+// Bot failing with scripted errors, successful messages are recorded
+type fakeBot struct {
+	mu    sync.Mutex
+	errs  []error // of next calls, nil is success
+	calls int
+	sent  []tgbotapi.MessageConfig
+}
+
+func (b *fakeBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.calls++
+	if len(b.errs) > 0 {
+		err := b.errs[0]
+		b.errs = b.errs[1:]
+		if err != nil {
+			return tgbotapi.Message{}, err
+		}
+	}
+
+	msg, _ := c.(tgbotapi.MessageConfig)
+	b.sent = append(b.sent, msg)
+
+	return tgbotapi.Message{MessageID: len(b.sent), Text: msg.Text}, nil
+}
+
+func (b *fakeBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	_, err := b.Send(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (b *fakeBot) texts() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var texts []string
+	for _, msg := range b.sent {
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
+
+// Wait until n messages are sent
+func (b *fakeBot) wait(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if texts := b.texts(); len(texts) >= n {
+			return texts
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("sent %q, want %d messages", b.texts(), n)
+	return nil
+}
+
+func newMessenger(t *testing.T, bot *fakeBot) *Messenger {
+	t.Helper()
+
+	m := New(bot, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func TestSendLongHTML(t *testing.T) {
+	bot := &fakeBot{}
+	m := newMessenger(t, bot)
+
+	msg := tgbotapi.NewMessage(1, "<b>"+strings.Repeat("ы", TextLimit)+"</b>\n&lt;конец&gt;")
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = 7
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+
+	sent, err := m.Send(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Text != "\n<конец>" {
+		t.Errorf("last sent message: got %q", sent.Text)
+	}
+
+	if len(bot.sent) != 2 {
+		t.Fatalf("got %d messages, want 2", len(bot.sent))
+	}
+	first, last := bot.sent[0], bot.sent[1]
+	if first.ParseMode != "" || strings.Contains(first.Text, "<b>") || textLen(first.Text) > TextLimit {
+		t.Errorf("first part must be plain text within limit: %q", first.Text[:20])
+	}
+	if first.ReplyToMessageID != 7 || first.ReplyMarkup != nil {
+		t.Errorf("first part must be a reply without buttons: %+v", first.BaseChat)
+	}
+	if last.ReplyToMessageID != 0 || last.ReplyMarkup == nil {
+		t.Errorf("last part must have buttons: %+v", last.BaseChat)
+	}
+}
+
+func TestPermanentError(t *testing.T) {
+	bot := &fakeBot{errs: []error{&tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: chat not found"}}}
+	m := newMessenger(t, bot)
+
+	if _, err := m.Send(tgbotapi.NewMessage(1, "текст")); err == nil {
+		t.Error("permanent error must be returned")
+	}
+	if bot.calls != 1 {
+		t.Errorf("calls: got %d, want 1", bot.calls)
+	}
+}
+
+func TestReplyNotFound(t *testing.T) {
+	bot := &fakeBot{errs: []error{&tgbotapi.Error{Code: http.StatusBadRequest, Message: "Bad Request: message to be replied not found"}}}
+	m := newMessenger(t, bot)
+
+	msg := tgbotapi.NewMessage(1, "ответ")
+	msg.ReplyToMessageID = 7
+	if _, err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(bot.sent) != 1 || bot.sent[0].ReplyToMessageID != 0 {
+		t.Errorf("message must be sent at once without reply: %+v", bot.sent)
+	}
+}
+
+func TestRetryInBackground(t *testing.T) {
+	bot := &fakeBot{errs: []error{&url.Error{Op: "Post", URL: "https://api.telegram.org", Err: errors.New("connection reset")}}}
+	m := newMessenger(t, bot)
+
+	start := time.Now()
+	for _, msg := range []tgbotapi.MessageConfig{
+		tgbotapi.NewMessage(1, "первое"),
+		tgbotapi.NewMessage(1, "второе"),
+		tgbotapi.NewMessage(2, "другой чат"),
+	} {
+		if _, err := m.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= retryBackoff {
+		t.Errorf("caller waited %s for retry", elapsed)
+	}
+
+	// Other chat isn't delayed, messages of the chat keep their order
+	want := []string{"другой чат", "первое", "второе"}
+	if got := bot.wait(t, 3); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Queue of the chat is gone after delivery
+	if _, err := m.Send(tgbotapi.NewMessage(1, "третье")); err != nil {
+		t.Fatal(err)
+	}
+	if got := bot.texts(); len(got) != 4 {
+		t.Errorf("message must be sent at once: %q", got)
+	}
+}
+
+func TestCloseDropsDelayed(t *testing.T) {
+	bot := &fakeBot{errs: []error{&tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		Message:            "Too Many Requests",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5},
+	}}}
+	m := newMessenger(t, bot)
+
+	if _, err := m.Send(tgbotapi.NewMessage(1, "первое")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Send(tgbotapi.NewMessage(1, "второе")); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	m.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("close waited %s for flood control", elapsed)
+	}
+	if got := bot.texts(); len(got) != 0 {
+		t.Errorf("delayed messages must be dropped: %q", got)
+	}
+}
+
+func TestFloodControlOverMaxWait(t *testing.T) {
+	bot := &fakeBot{errs: []error{&tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		Message:            "Too Many Requests",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 60},
+	}}}
+	m := newMessenger(t, bot)
+
+	if _, err := m.Request(tgbotapi.NewCallback("1", "")); err == nil {
+		t.Error("long flood control must fail the request")
+	}
+}
+
+func TestUndecodedResponse(t *testing.T) {
+	// Telegram has accepted the message, retry would duplicate it
+	bot := &fakeBot{errs: []error{errors.New("unexpected end of JSON input")}}
+	m := newMessenger(t, bot)
+
+	if _, err := m.Send(tgbotapi.NewMessage(1, "текст")); err == nil {
+		t.Error("error must be returned")
+	}
+	if bot.calls != 1 {
+		t.Errorf("calls: got %d, want 1", bot.calls)
+	}
+}
+
+// Bot API answering sendPhoto with scripted errors, the next calls succeed
+type uploadServer struct {
+	mu      sync.Mutex
+	errs    []tgbotapi.APIResponse
+	replies []string // reply_to_message_id of every sendPhoto
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if strings.HasSuffix(r.URL.Path, "/getMe") {
+		json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: json.RawMessage(`{"id":1,"is_bot":true}`)})
+		return
+	}
+
+	r.ParseMultipartForm(1 << 20)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies = append(s.replies, r.FormValue("reply_to_message_id"))
+	if len(s.errs) > 0 {
+		resp := s.errs[0]
+		s.errs = s.errs[1:]
+		w.WriteHeader(resp.ErrorCode)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: json.RawMessage(`{"message_id":1}`)})
+}
+
+// Wait until n photos are requested
+func (s *uploadServer) wait(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		replies := slices.Clone(s.replies)
+		s.mu.Unlock()
+		if len(replies) >= n {
+			return replies
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("want %d photos", n)
+	return nil
+}
+
+func TestUploadFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    tgbotapi.APIResponse
+		replies []string
+	}{
+		{
+			name:    "reply not found",
+			resp:    tgbotapi.APIResponse{ErrorCode: http.StatusBadRequest, Description: "Bad Request: message to be replied not found"},
+			replies: []string{"7", ""},
+		},
+		{
+			name: "flood control",
+			resp: tgbotapi.APIResponse{
+				ErrorCode:   http.StatusTooManyRequests,
+				Description: "Too Many Requests: retry after 1",
+				Parameters:  &tgbotapi.ResponseParameters{RetryAfter: 1},
+			},
+			replies: []string{"7", "7"},
+		},
+		{
+			name:    "server error",
+			resp:    tgbotapi.APIResponse{ErrorCode: http.StatusBadGateway, Description: "Bad Gateway"},
+			replies: []string{"7", "7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &uploadServer{errs: []tgbotapi.APIResponse{tt.resp}}
+			server := httptest.NewServer(api)
+			t.Cleanup(server.Close)
+
+			bot, err := tgbotapi.NewBotAPIWithClient("1:TEST", server.URL+"/bot%s/%s", server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := New(bot, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			t.Cleanup(m.Close)
+
+			photo := tgbotapi.NewPhoto(1, tgbotapi.FileBytes{Name: "chart.png", Bytes: []byte("png")})
+			photo.ReplyToMessageID = 7
+			if _, err := m.Send(photo); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := api.wait(t, len(tt.replies)); !slices.Equal(got, tt.replies) {
+				t.Errorf("reply_to_message_id: got %q, want %q", got, tt.replies)
+			}
+		})
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  tgbotapi.Error
+		want int
+	}{
+		{err: tgbotapi.Error{Code: http.StatusForbidden, Message: "Forbidden: bot was blocked by the user"}, want: http.StatusForbidden},
+		{err: tgbotapi.Error{Message: "Bad Request: message to be replied not found"}, want: http.StatusBadRequest},
+		{err: tgbotapi.Error{Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3}}, want: http.StatusTooManyRequests},
+		{err: tgbotapi.Error{Message: "Internal Server Error"}, want: http.StatusInternalServerError},
+		{err: tgbotapi.Error{Message: "unknown"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := codeOf(&tt.err); got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.err.Message, got, tt.want)
+		}
+	}
+}
@@ -8,22 +8,50 @@ type Cordinates struct {
 }
 
 type CordinatesResponse struct {
-	Name string  `json:"name"`
-	Lat  float64 `json:"lat"`
-	Lon  float64 `json:"lon"`
+	Name       string            `json:"name"`
+	LocalNames map[string]string `json:"local_names"`
+	Lat        float64           `json:"lat"`
+	Lon        float64           `json:"lon"`
+	Country    string            `json:"country"`
+	State      string            `json:"state"`
 }
 
 type Weather struct {
-	Date        string
-	Description string
-	Temp        float64
-	Humidity    int64
-	Speed       float64
+	Date          time.Time // in the location's timezone
+	Code          int       // OpenWeather condition code
+	Icon          string    // OpenWeather icon, e.g. "10d", "n" suffix is night
+	Description   string
+	Temp          float64
+	Humidity      int64
+	Speed         float64
+	Precipitation float64 // rain and snow volume in mm
+}
+
+type DailyForecast struct {
+	Date          time.Time
+	MinTemp       float64
+	MaxTemp       float64
+	Code          int // condition code of the most frequent description
+	Description   string
+	Precipitation float64
+	MaxSpeed      float64
+}
+
+// Recorded temperatures of one day
+type DayTemperature struct {
+	Date     time.Time
+	MinTemp  float64
+	MaxTemp  float64
+	MeanTemp float64
 }
 
 type WeatherResponse struct {
-	Weather []struct {
+	Date     int64 `json:"dt"`
+	Timezone int   `json:"timezone"` // shift in seconds from UTC
+	Weather  []struct {
+		ID          int    `json:"id"`
 		Description string `json:"description"`
+		Icon        string `json:"icon"`
 	} `json:"weather"`
 	Main struct {
 		Temp     float64 `json:"temp"`
@@ -41,13 +69,24 @@ type ForecastWeatherResponse struct {
 			Humidity int64   `json:"humidity"`
 		} `json:"main"`
 		Weather []struct {
+			ID          int    `json:"id"`
 			Description string `json:"description"`
+			Icon        string `json:"icon"`
 		} `json:"weather"`
 		Wind struct {
 			Speed float64 `json:"speed"`
 		} `json:"wind"`
-		Date string `json:"dt_txt"`
+		Rain struct {
+			Volume float64 `json:"3h"`
+		} `json:"rain"`
+		Snow struct {
+			Volume float64 `json:"3h"`
+		} `json:"snow"`
+		Date int64 `json:"dt"`
 	} `json:"list"`
+	City struct {
+		Timezone int `json:"timezone"` // shift in seconds from UTC
+	} `json:"city"`
 }
 
 type CacheWeather struct {
@@ -57,3 +96,11 @@ type CacheWeather struct {
 	Weather   Weather
 	UpdatedAt time.Time
 }
+
+type CacheForecast struct {
+	City      string
+	Lat       float64
+	Lon       float64
+	Items     []Weather
+	UpdatedAt time.Time
+}
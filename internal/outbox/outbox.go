@@ -0,0 +1,202 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+const (
+	pollInterval = time.Second // check of rescheduled messages
+	batchSize    = 100
+	retryBackoff = 30 * time.Second // doubled after every failed attempt
+)
+
+type Sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// Outbox delivers bulk messages through persistent queue
+// within Telegram limits: global messages per second and one message per second per chat
+type Outbox struct {
+	cfg     config.Outbox
+	log     *slog.Logger
+	bot     Sender
+	storage storage.Repository
+	chats   *ratelimit.Limiter
+	wake    chan struct{}
+
+	mu          sync.Mutex
+	pausedUntil time.Time // flood control of Telegram
+}
+
+func New(cfg config.Outbox, log *slog.Logger, bot Sender, storage storage.Repository) *Outbox {
+	return &Outbox{
+		cfg:     cfg,
+		log:     log,
+		bot:     bot,
+		storage: storage,
+		chats:   ratelimit.New(cfg.ChatRate, 1),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Put message in queue, it survives restart until delivered
+func (o *Outbox) Enqueue(ctx context.Context, chatID int64, text string) error {
+	op := "outbox.enqueue"
+
+	if _, err := o.storage.EnqueueMessage(ctx, storage.OutboxMessage{ChatID: chatID, Text: text}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Deliver queued messages until context is done
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	pace := time.NewTicker(time.Duration(float64(time.Second) / o.cfg.Rate))
+	defer pace.Stop()
+
+	o.log.Info("outbox is started")
+
+	for {
+		o.deliver(ctx, pace)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// Send due messages, one message per tick of pace
+func (o *Outbox) deliver(ctx context.Context, pace *time.Ticker) {
+	for {
+		if o.paused() {
+			return
+		}
+
+		messages, err := o.storage.DueMessages(ctx, time.Now(), batchSize)
+		if err != nil {
+			o.log.Error(err.Error())
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		for _, message := range messages {
+			if o.paused() {
+				return
+			}
+
+			// Next message of the same chat waits for its turn
+			if !o.chats.Allow(message.ChatID) {
+				o.reschedule(ctx, message, message.Attempts, time.Now().Add(time.Duration(float64(time.Second)/o.cfg.ChatRate)))
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-pace.C:
+			}
+
+			o.send(ctx, message)
+		}
+	}
+}
+
+func (o *Outbox) send(ctx context.Context, message storage.OutboxMessage) {
+	_, err := o.bot.Send(tgbotapi.NewMessage(message.ChatID, message.Text))
+	if err == nil {
+		metrics.Delivered.Add(1)
+		if err := o.storage.DeleteMessage(ctx, message.ID); err != nil {
+			o.log.Error(err.Error())
+		}
+		return
+	}
+
+	var apiErr *tgbotapi.Error
+	errors.As(err, &apiErr)
+
+	switch {
+	case apiErr != nil && apiErr.Code == http.StatusTooManyRequests:
+		// Flood control, whole queue waits
+		wait := time.Duration(max(apiErr.RetryAfter, 1)) * time.Second
+		o.log.Info("outbox paused by flood control", slog.Duration("retry_after", wait))
+		o.pause(wait)
+		o.reschedule(ctx, message, message.Attempts, time.Now().Add(wait))
+
+	case apiErr != nil && apiErr.Code == http.StatusForbidden:
+		// Bot is blocked or kicked, nothing to deliver to this chat anymore
+		metrics.DeliveryFailed.Add(1)
+		o.log.Info("chat blocked the bot", slog.Int64("chat_id", message.ChatID))
+		if err := o.storage.DeleteChatMessages(ctx, message.ChatID); err != nil {
+			o.log.Error(err.Error())
+		}
+		if err := o.storage.DisableSubscriptions(ctx, message.ChatID); err != nil {
+			o.log.Error(err.Error())
+		}
+
+	default:
+		attempts := message.Attempts + 1
+		if attempts >= o.cfg.Attempts {
+			metrics.DeliveryFailed.Add(1)
+			o.log.Error("message is not delivered",
+				slog.Int64("chat_id", message.ChatID),
+				slog.Int("attempts", attempts),
+				slog.String("error", err.Error()))
+			if err := o.storage.DeleteMessage(ctx, message.ID); err != nil {
+				o.log.Error(err.Error())
+			}
+			return
+		}
+
+		o.log.Info("message delivery failed, retrying",
+			slog.Int64("chat_id", message.ChatID),
+			slog.Int("attempts", attempts),
+			slog.String("error", err.Error()))
+		o.reschedule(ctx, message, attempts, time.Now().Add(retryBackoff<<(attempts-1)))
+	}
+}
+
+func (o *Outbox) reschedule(ctx context.Context, message storage.OutboxMessage, attempts int, next time.Time) {
+	if err := o.storage.RescheduleMessage(ctx, message.ID, attempts, next); err != nil {
+		o.log.Error(err.Error())
+	}
+}
+
+func (o *Outbox) pause(wait time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.pausedUntil = time.Now().Add(wait)
+}
+
+func (o *Outbox) paused() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return time.Now().Before(o.pausedUntil)
+}
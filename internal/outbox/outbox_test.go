@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/storage"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
+)
+
+// Sender failing with errors of chats, delivered texts are recorded
+type fakeSender struct {
+	mu    sync.Mutex
+	errs  map[int64]error
+	texts []string
+}
+
+func (s *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := c.(tgbotapi.MessageConfig)
+	if err := s.errs[msg.ChatID]; err != nil {
+		return tgbotapi.Message{}, err
+	}
+	s.texts = append(s.texts, msg.Text)
+
+	return tgbotapi.Message{}, nil
+}
+
+func (s *fakeSender) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.texts)
+}
+
+func newOutbox(t *testing.T, sender *fakeSender, attempts int) (*Outbox, *sqlite.Storage) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "weatherbot.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return New(config.Outbox{Rate: 1000, ChatRate: 1, Attempts: attempts}, log, sender, s), s
+}
+
+func enqueue(t *testing.T, o *Outbox, chatID int64, texts ...string) {
+	t.Helper()
+
+	for _, text := range texts {
+		if err := o.Enqueue(context.Background(), chatID, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Run one delivery pass without waiting for pace
+func deliver(o *Outbox) {
+	pace := time.NewTicker(time.Millisecond)
+	defer pace.Stop()
+
+	o.deliver(context.Background(), pace)
+}
+
+// All queued messages regardless of their schedule
+func queue(t *testing.T, s *sqlite.Storage) []storage.OutboxMessage {
+	t.Helper()
+
+	messages, err := s.DueMessages(context.Background(), time.Now().Add(24*time.Hour), batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return messages
+}
+
+func TestDeliveryPerChatRate(t *testing.T) {
+	sender := &fakeSender{}
+	o, s := newOutbox(t, sender, 5)
+
+	enqueue(t, o, 1, "первое")
+	enqueue(t, o, 2, "другой чат")
+	enqueue(t, o, 1, "второе")
+
+	start := time.Now()
+	deliver(o)
+
+	if got, want := sender.sent(), []string{"первое", "другой чат"}; !slices.Equal(got, want) {
+		t.Errorf("sent: got %q, want %q", got, want)
+	}
+
+	// Second message of the chat waits for its turn without losing an attempt
+	messages := queue(t, s)
+	if len(messages) != 1 || messages[0].Text != "второе" || messages[0].Attempts != 0 {
+		t.Fatalf("queue: got %+v", messages)
+	}
+	if next := messages[0].NextAttemptAt; next.Before(start.Add(500 * time.Millisecond)) {
+		t.Errorf("next attempt %s must be about a second later", next)
+	}
+}
+
+func TestFloodControl(t *testing.T) {
+	sender := &fakeSender{errs: map[int64]error{1: &tgbotapi.Error{
+		Code:               http.StatusTooManyRequests,
+		Message:            "Too Many Requests",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 30},
+	}}}
+	o, s := newOutbox(t, sender, 5)
+
+	enqueue(t, o, 1, "первое")
+	enqueue(t, o, 2, "другой чат")
+
+	start := time.Now()
+	deliver(o)
+
+	// Whole queue waits, flood control isn't a failed attempt
+	if !o.paused() {
+		t.Error("outbox must be paused")
+	}
+	if sent := sender.sent(); len(sent) != 0 {
+		t.Errorf("sent during pause: %q", sent)
+	}
+
+	messages := queue(t, s)
+	if len(messages) != 2 {
+		t.Fatalf("queue: got %+v", messages)
+	}
+	for _, message := range messages {
+		if message.ChatID == 1 && (message.Attempts != 0 || message.NextAttemptAt.Before(start.Add(29*time.Second))) {
+			t.Errorf("message must be rescheduled after retry_after: %+v", message)
+		}
+	}
+
+	// Nothing is sent until the pause ends
+	o.pause(0)
+	delete(sender.errs, 1)
+	deliver(o)
+	if got := sender.sent(); !slices.Equal(got, []string{"другой чат"}) {
+		t.Errorf("sent after pause: got %q", got)
+	}
+}
+
+func TestBlockedChat(t *testing.T) {
+	sender := &fakeSender{errs: map[int64]error{1: &tgbotapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Forbidden: bot was blocked by the user",
+	}}}
+	o, s := newOutbox(t, sender, 5)
+	ctx := context.Background()
+
+	if _, err := s.AddSubscription(ctx, storage.Subscription{ChatID: 1, City: "Орск", Time: "08:00", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, o, 1, "первое", "второе")
+
+	deliver(o)
+
+	if messages := queue(t, s); len(messages) != 0 {
+		t.Errorf("messages of blocked chat must be dropped: %+v", messages)
+	}
+	subscriptions, err := s.Subscriptions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].Enabled {
+		t.Errorf("subscriptions must be disabled: %+v", subscriptions)
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	sender := &fakeSender{errs: map[int64]error{1: errors.New("connection reset")}}
+	o, s := newOutbox(t, sender, 2)
+
+	enqueue(t, o, 1, "первое")
+
+	start := time.Now()
+	deliver(o)
+
+	messages := queue(t, s)
+	if len(messages) != 1 || messages[0].Attempts != 1 {
+		t.Fatalf("queue after first attempt: got %+v", messages)
+	}
+	if next := messages[0].NextAttemptAt; next.Before(start.Add(retryBackoff - time.Second)) {
+		t.Errorf("next attempt %s must wait for backoff", next)
+	}
+
+	// Last attempt drops the message
+	if err := s.RescheduleMessage(context.Background(), messages[0].ID, messages[0].Attempts, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	o.chats = ratelimit.New(o.cfg.ChatRate, 1)
+	deliver(o)
+
+	if messages := queue(t, s); len(messages) != 0 {
+		t.Errorf("message must be dropped after %d attempts: %+v", o.cfg.Attempts, messages)
+	}
+}
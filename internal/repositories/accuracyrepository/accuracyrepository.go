@@ -0,0 +1,150 @@
+package accuracyrepository
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+const (
+	resolveInterval = time.Hour
+	// Observation closer to target than window counts as observed value of the prediction
+	matchWindow = 90 * time.Minute
+	// Predictions without observation are dropped after
+	maxWait = 24 * time.Hour
+)
+
+// Upper bounds of lead time buckets in hours
+var leadBuckets = []int{3, 6, 12, 24, 48, 72, 96, 120}
+
+type AccuracyRepository struct {
+	Log     *slog.Logger
+	Storage storage.Repository
+}
+
+func New(log *slog.Logger, storage storage.Repository) *AccuracyRepository {
+	return &AccuracyRepository{
+		Log:     log,
+		Storage: storage,
+	}
+}
+
+// Save forecast of provider for location issued at issued, errors are only logged
+func (ar *AccuracyRepository) RecordForecast(
+	ctx context.Context, provider string, location models.CordinatesResponse, issued time.Time, items []models.Weather,
+) {
+	var predictions []storage.Prediction
+	for _, item := range items {
+		lead := item.Date.Sub(issued)
+		if lead <= 0 {
+			continue
+		}
+
+		predictions = append(predictions, storage.Prediction{
+			Provider:  provider,
+			City:      strings.ToLower(location.Name),
+			Lat:       location.Lat,
+			Lon:       location.Lon,
+			IssuedAt:  issued,
+			TargetAt:  item.Date,
+			LeadHours: leadBucket(lead),
+			Temp:      item.Temp,
+			Speed:     item.Speed,
+		})
+	}
+
+	if err := ar.Storage.SavePredictions(ctx, predictions); err != nil {
+		ar.Log.Error(err.Error())
+	}
+}
+
+// Compare predictions with observations which are already recorded
+func (ar *AccuracyRepository) Resolve(ctx context.Context, now time.Time) error {
+	op := "repositories.accuracyrepository.resolve"
+
+	// Observations after target may still come within window
+	matches, err := ar.Storage.MatchPredictions(ctx, now.Add(-matchWindow), matchWindow)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := ar.Storage.ResolvePredictions(ctx, matches); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	dropped, err := ar.Storage.DeletePredictions(ctx, now.Add(-maxWait))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ar.Log.Info("forecasts resolved", slog.Int("matched", len(matches)), slog.Int64("dropped", dropped))
+
+	return ar.publish(ctx)
+}
+
+func (ar *AccuracyRepository) Stats(ctx context.Context) ([]storage.ForecastStats, error) {
+	op := "repositories.accuracyrepository.stats"
+
+	stats, err := ar.Storage.ForecastStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// Resolve predictions periodically until context is done
+func (ar *AccuracyRepository) Run(ctx context.Context) {
+	ticker := time.NewTicker(resolveInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ar.Resolve(ctx, time.Now()); err != nil {
+			ar.Log.Error(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update metrics with current stats
+func (ar *AccuracyRepository) publish(ctx context.Context) error {
+	stats, err := ar.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, st := range stats {
+		key := fmt.Sprintf("%s:%dh", st.Provider, st.LeadHours)
+
+		mae := new(expvar.Float)
+		mae.Set(st.TempMAE)
+		metrics.ForecastTempMAE.Set(key, mae)
+
+		samples := new(expvar.Int)
+		samples.Set(int64(st.Count))
+		metrics.ForecastSamples.Set(key, samples)
+	}
+
+	return nil
+}
+
+func leadBucket(lead time.Duration) int {
+	for _, hours := range leadBuckets {
+		if lead <= time.Duration(hours)*time.Hour {
+			return hours
+		}
+	}
+
+	return leadBuckets[len(leadBuckets)-1]
+}
@@ -9,25 +9,36 @@ import (
 	"github.com/m1al04949/weatherbot/internal/cache/redis"
 	"github.com/m1al04949/weatherbot/internal/clients/openweather"
 	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
 	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/repositories/observationrepository"
 )
 
 type CacheRepository struct {
-	Cfg   *config.Config
-	Log   *slog.Logger
-	Cache *redis.WeatherCache
+	Cfg          *config.Config
+	Log          *slog.Logger
+	Cache        *redis.WeatherCache
+	Observations *observationrepository.ObservationRepository
 }
 
-func New(cfg *config.Config, log *slog.Logger, cache *redis.WeatherCache) *CacheRepository {
+func New(
+	cfg *config.Config, log *slog.Logger,
+	cache *redis.WeatherCache, observations *observationrepository.ObservationRepository,
+) *CacheRepository {
 	return &CacheRepository{
-		Cfg:   cfg,
-		Log:   log,
-		Cache: cache,
+		Cfg:          cfg,
+		Log:          log,
+		Cache:        cache,
+		Observations: observations,
 	}
 }
 
-func (cr *CacheRepository) FreshCache(ctx context.Context, log *slog.Logger, owClient *openweather.OpenWeatherClient) {
-	cities := []string{"Санкт-Петербург", "Москва", "Коломна", "Орск"}
+// Default cities are refreshed regardless of daily budget, calls are only counted
+func (cr *CacheRepository) FreshCache(
+	ctx context.Context, log *slog.Logger,
+	owClient *openweather.OpenWeatherClient, budget *ratelimit.Budget,
+) {
+	cities := cr.Cfg.Cities
 	ticker := time.NewTicker(time.Duration(cr.Cfg.TTL) * time.Minute)
 	defer ticker.Stop()
 
@@ -40,6 +51,7 @@ func (cr *CacheRepository) FreshCache(ctx context.Context, log *slog.Logger, owC
 		case <-ticker.C:
 			for _, city := range cities {
 				cacheWeather.City = city
+				budget.Add(2)
 				cord, err := owClient.Coordinates(city)
 				if err != nil {
 					log.Error(fmt.Sprintf("error get coordinates for %s: %s)", city, err.Error()))
@@ -55,6 +67,7 @@ func (cr *CacheRepository) FreshCache(ctx context.Context, log *slog.Logger, owC
 				}
 
 				cacheWeather.Weather = *weather
+				cr.Observations.Record(ctx, cacheWeather)
 				if err := cr.Cache.UpdateWeather(ctx, cacheWeather); err != nil {
 					log.Error(fmt.Sprintf("error refresh cache for %s: %s)", city, err.Error()))
 				}
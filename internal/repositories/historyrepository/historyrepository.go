@@ -0,0 +1,56 @@
+package historyrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+// Source of observed weather, e.g. Open-Meteo archive
+type Provider interface {
+	Day(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error)
+}
+
+type HistoryRepository struct {
+	Log      *slog.Logger
+	Provider Provider
+	Storage  storage.Repository
+}
+
+func New(log *slog.Logger, provider Provider, storage storage.Repository) *HistoryRepository {
+	return &HistoryRepository{
+		Log:      log,
+		Provider: provider,
+		Storage:  storage,
+	}
+}
+
+// Observed weather of the day, fetched days are stored locally
+// because the past doesn't change
+func (hr *HistoryRepository) Day(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error) {
+	op := "repositories.historyrepository.day"
+
+	day, err := hr.Storage.HistoryDay(ctx, lat, lon, date)
+	if err == nil {
+		return day, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		hr.Log.Error(err.Error())
+	}
+
+	day, err = hr.Provider.Day(ctx, lat, lon, date)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := hr.Storage.SaveHistoryDay(ctx, lat, lon, *day); err != nil {
+		hr.Log.Error(err.Error())
+	}
+
+	return day, nil
+}
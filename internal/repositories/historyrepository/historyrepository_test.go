@@ -0,0 +1,88 @@
+package historyrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
+)
+
+var errNoData = errors.New("no data")
+
+// Provider with observed days by date, others have no data yet
+type fakeProvider struct {
+	days  map[string]models.DailyForecast
+	calls int
+}
+
+func (p *fakeProvider) Day(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error) {
+	p.calls++
+
+	day, ok := p.days[date.Format("2006-01-02")]
+	if !ok {
+		return nil, fmt.Errorf("fake: %w", errNoData)
+	}
+
+	return &day, nil
+}
+
+func newRepository(t *testing.T, provider Provider) *HistoryRepository {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "weatherbot.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return New(log, provider, s)
+}
+
+func TestDay(t *testing.T) {
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	want := models.DailyForecast{
+		Date: date, MinTemp: -4.2, MaxTemp: 1.5, Code: 600, Description: "небольшой снег", Precipitation: 2.1, MaxSpeed: 6.3,
+	}
+	provider := &fakeProvider{days: map[string]models.DailyForecast{"2024-03-15": want}}
+	hr := newRepository(t, provider)
+
+	for range 2 {
+		day, err := hr.Day(context.Background(), 55.75, 37.62, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *day != want {
+			t.Errorf("got %+v, want %+v", day, want)
+		}
+	}
+
+	// The past doesn't change, the stored day is used
+	if provider.calls != 1 {
+		t.Errorf("provider calls: got %d, want 1", provider.calls)
+	}
+}
+
+func TestDayWithoutData(t *testing.T) {
+	provider := &fakeProvider{}
+	hr := newRepository(t, provider)
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	for range 2 {
+		if _, err := hr.Day(context.Background(), 55.75, 37.62, date); !errors.Is(err, errNoData) {
+			t.Fatalf("got %v, want %v", err, errNoData)
+		}
+	}
+
+	// Missing day isn't stored, it's requested again when archived
+	if provider.calls != 2 {
+		t.Errorf("provider calls: got %d, want 2", provider.calls)
+	}
+}
@@ -0,0 +1,170 @@
+package observationrepository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+const (
+	compactInterval = time.Hour
+	day             = 24 * time.Hour
+)
+
+type ObservationRepository struct {
+	Cfg     config.Observations
+	Log     *slog.Logger
+	Storage storage.Repository
+}
+
+func New(cfg config.Observations, log *slog.Logger, storage storage.Repository) *ObservationRepository {
+	return &ObservationRepository{
+		Cfg:     cfg,
+		Log:     log,
+		Storage: storage,
+	}
+}
+
+// Mean temperature of a bucket of time
+type bucket struct {
+	sum    float64
+	count  int
+	period time.Duration
+}
+
+// Save snapshot of weather, errors are only logged.
+// Snapshots are kept by place, whatever name or coordinates it was requested with
+func (or *ObservationRepository) Record(ctx context.Context, weather models.CacheWeather) {
+	err := or.Storage.SaveObservation(ctx, storage.Observation{
+		City:        strings.ToLower(weather.City),
+		Lat:         weather.Lat,
+		Lon:         weather.Lon,
+		ObservedAt:  weather.Weather.Date,
+		Temp:        weather.Weather.Temp,
+		MinTemp:     weather.Weather.Temp,
+		MaxTemp:     weather.Weather.Temp,
+		Humidity:    float64(weather.Weather.Humidity),
+		Speed:       weather.Weather.Speed,
+		Code:        weather.Weather.Code,
+		Description: weather.Weather.Description,
+	})
+	if err != nil {
+		or.Log.Error(err.Error())
+	}
+}
+
+// Temperatures of the place by day for the last days, today included,
+// days without observations are skipped
+func (or *ObservationRepository) Trend(ctx context.Context, lat, lon float64, days int, now time.Time) ([]models.DayTemperature, error) {
+	op := "repositories.observationrepository.trend"
+
+	observations, err := or.Storage.Observations(ctx, lat, lon, now.Add(-time.Duration(days)*day))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		result  []models.DayTemperature
+		buckets map[int64]*bucket // of the last day by start
+	)
+	for _, o := range observations {
+		date := time.Date(o.ObservedAt.Year(), o.ObservedAt.Month(), o.ObservedAt.Day(), 0, 0, 0, 0, o.ObservedAt.Location())
+
+		if len(result) == 0 || !result[len(result)-1].Date.Equal(date) {
+			result = append(result, models.DayTemperature{
+				Date:    date,
+				MinTemp: math.Inf(1),
+				MaxTemp: math.Inf(-1),
+			})
+			buckets = make(map[int64]*bucket)
+		}
+
+		last := &result[len(result)-1]
+		last.MinTemp = math.Min(last.MinTemp, o.MinTemp)
+		last.MaxTemp = math.Max(last.MaxTemp, o.MaxTemp)
+
+		// Snapshots are averaged by hour first, so that hours with many requests
+		// don't outweigh the others, downsampled rows weigh as their period
+		period := max(o.Period, time.Hour)
+		start := o.ObservedAt.Truncate(period).Unix()
+		b, ok := buckets[start]
+		if !ok {
+			b = &bucket{period: period}
+			buckets[start] = b
+		}
+		b.sum += o.Temp
+		b.count++
+
+		last.MeanTemp = mean(buckets)
+	}
+
+	// The oldest day is partial
+	if len(result) > days {
+		result = result[len(result)-days:]
+	}
+
+	return result, nil
+}
+
+// Mean of bucket means weighted by their periods
+func mean(buckets map[int64]*bucket) float64 {
+	var sum, weight float64
+	for _, b := range buckets {
+		sum += b.sum / float64(b.count) * b.period.Hours()
+		weight += b.period.Hours()
+	}
+
+	return sum / weight
+}
+
+// Downsample old observations and drop expired ones
+func (or *ObservationRepository) Compact(ctx context.Context, now time.Time) error {
+	op := "repositories.observationrepository.compact"
+
+	raw := now.Add(-time.Duration(or.Cfg.RawDays) * day).Truncate(time.Hour)
+	hours, err := or.Storage.DownsampleObservations(ctx, 0, time.Hour, raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hourly := now.Add(-time.Duration(or.Cfg.HourlyDays) * day).Truncate(day)
+	days, err := or.Storage.DownsampleObservations(ctx, time.Hour, day, hourly)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := or.Storage.DeleteObservations(ctx, day, now.Add(-time.Duration(or.Cfg.DailyDays)*day))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	or.Log.Info("observations compacted",
+		slog.Int64("hours", hours), slog.Int64("days", days), slog.Int64("deleted", deleted))
+
+	return nil
+}
+
+// Compact observations periodically until context is done
+func (or *ObservationRepository) Run(ctx context.Context) {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := or.Compact(ctx, time.Now()); err != nil {
+			or.Log.Error(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
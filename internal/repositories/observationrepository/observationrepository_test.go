@@ -0,0 +1,195 @@
+package observationrepository
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/config"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+	"github.com/m1al04949/weatherbot/internal/storage/sqlite"
+)
+
+var msk = time.FixedZone("MSK", 3*60*60)
+
+// Kazan
+const lat, lon = 55.79, 49.12
+
+func newRepository(t *testing.T) (*ObservationRepository, *sqlite.Storage) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "weatherbot.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return New(config.Observations{RawDays: 2, HourlyDays: 30, DailyDays: 365}, log, s), s
+}
+
+func save(t *testing.T, s *sqlite.Storage, at time.Time, period time.Duration, temp float64) {
+	t.Helper()
+
+	err := s.SaveObservation(context.Background(), storage.Observation{
+		City: "казань", Lat: lat, Lon: lon, ObservedAt: at, Period: period,
+		Temp: temp, MinTemp: temp, MaxTemp: temp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTrend(t *testing.T) {
+	or, s := newRepository(t)
+	now := time.Date(2025, 10, 20, 18, 0, 0, 0, msk)
+	yesterday := time.Date(2025, 10, 19, 0, 0, 0, 0, msk)
+
+	// Many requests in one hour count as one hour
+	for minute := 0; minute < 60; minute += 10 {
+		save(t, s, yesterday.Add(10*time.Hour+time.Duration(minute)*time.Minute), 0, 20)
+	}
+	save(t, s, yesterday.Add(14*time.Hour), 0, 10)
+
+	// Hourly mean weighs as an hour
+	save(t, s, time.Date(2025, 10, 20, 9, 0, 0, 0, msk), time.Hour, 3)
+	save(t, s, time.Date(2025, 10, 20, 12, 5, 0, 0, msk), 0, 4)
+	save(t, s, time.Date(2025, 10, 20, 12, 35, 0, 0, msk), 0, 6)
+
+	days, err := or.Trend(context.Background(), lat, lon, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.DayTemperature{
+		{Date: yesterday, MinTemp: 10, MaxTemp: 20, MeanTemp: 15},
+		{Date: time.Date(2025, 10, 20, 0, 0, 0, 0, msk), MinTemp: 3, MaxTemp: 6, MeanTemp: 4},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("got %+v, want %+v", days, want)
+	}
+	for i := range want {
+		got := days[i]
+		if !got.Date.Equal(want[i].Date) || got.MinTemp != want[i].MinTemp || got.MaxTemp != want[i].MaxTemp ||
+			!equal(got.MeanTemp, want[i].MeanTemp) {
+			t.Errorf("day %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	or, s := newRepository(t)
+	ctx := context.Background()
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, msk)
+
+	// Raw snapshots out of raw retention
+	old := time.Date(2025, 10, 15, 0, 0, 0, 0, msk)
+	save(t, s, old.Add(10*time.Hour), 0, 10)
+	save(t, s, old.Add(10*time.Hour+20*time.Minute), 0, 20)
+	save(t, s, old.Add(10*time.Hour+40*time.Minute), 0, 30)
+	save(t, s, old.Add(15*time.Hour), 0, 0)
+	// Recent snapshot stays raw
+	save(t, s, now.Add(-time.Hour), 0, 8)
+	// Hourly means out of hourly retention
+	hourly := time.Date(2025, 9, 10, 0, 0, 0, 0, time.UTC)
+	save(t, s, hourly.Add(10*time.Hour), time.Hour, 5)
+	save(t, s, hourly.Add(11*time.Hour), time.Hour, 7)
+	// Daily mean out of daily retention
+	save(t, s, time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), 24*time.Hour, 1)
+
+	before, err := or.Trend(ctx, lat, lon, 10, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := or.Compact(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	observations, err := s.Observations(ctx, lat, lon, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		period time.Duration
+		temp   float64
+	}
+	want := []row{
+		{period: 24 * time.Hour, temp: 6},
+		{period: time.Hour, temp: 20},
+		{period: time.Hour, temp: 0},
+		{period: 0, temp: 8},
+	}
+	if len(observations) != len(want) {
+		t.Fatalf("got %+v", observations)
+	}
+	for i, o := range observations {
+		if o.Period != want[i].period || !equal(o.Temp, want[i].temp) {
+			t.Errorf("row %d: got period %s temp %v, want %+v", i, o.Period, o.Temp, want[i])
+		}
+	}
+
+	// Downsampling keeps the trend
+	after, err := or.Trend(ctx, lat, lon, 10, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("days before %+v, after %+v", before, after)
+	}
+	for i := range before {
+		if !equal(before[i].MeanTemp, after[i].MeanTemp) || before[i].MinTemp != after[i].MinTemp ||
+			before[i].MaxTemp != after[i].MaxTemp {
+			t.Errorf("day %d: before %+v, after %+v", i, before[i], after[i])
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	or, s := newRepository(t)
+	ctx := context.Background()
+	at := time.Date(2025, 10, 20, 12, 0, 0, 0, msk)
+
+	// The same place by different names and coordinates is one series
+	for i, weather := range []models.CacheWeather{
+		{City: "Казань", Lat: lat, Lon: lon},
+		{City: "Kazan", Lat: 55.7887, Lon: 49.1221},
+		{City: "55.79,49.12", Lat: 55.7904, Lon: 49.1198},
+		{City: "Kazan", Lat: lat, Lon: lon}, // duplicate of the first snapshot
+		{City: "Москва", Lat: 55.75, Lon: 37.62},
+	} {
+		weather.Weather = models.Weather{Date: at.Add(time.Duration(i%3) * time.Hour), Temp: float64(i), Code: 500}
+		or.Record(ctx, weather)
+	}
+
+	observations, err := s.Observations(ctx, lat, lon, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 3 {
+		t.Fatalf("got %+v, want 3 snapshots", observations)
+	}
+	for i, o := range observations {
+		if o.Temp != float64(i) || o.Code != 500 {
+			t.Errorf("snapshot %d: got %+v", i, o)
+		}
+	}
+
+	days, err := or.Trend(ctx, 55.7887, 49.1221, 1, at.Add(6*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].MinTemp != 0 || days[0].MaxTemp != 2 {
+		t.Errorf("trend: got %+v", days)
+	}
+}
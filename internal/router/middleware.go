@@ -0,0 +1,86 @@
+package router
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+)
+
+type Limiter interface {
+	Allow(key int64) bool
+}
+
+// Log every handled update
+func Logging(log *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			start := time.Now()
+			next(ctx, update)
+
+			attrs := []any{
+				slog.String("route", Route(ctx)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if user := update.SentFrom(); user != nil {
+				attrs = append(attrs, slog.String("username", user.UserName))
+			}
+			if update.Message != nil {
+				attrs = append(attrs, slog.String("message", update.Message.Text))
+			}
+			log.Info("update handled", attrs...)
+		}
+	}
+}
+
+// Recover panic of handler, the bot keeps working
+func Recovery(log *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			defer func() {
+				if err := recover(); err != nil {
+					metrics.Panics.Add(1)
+					log.Error("panic in handler",
+						slog.String("route", Route(ctx)),
+						slog.Any("error", err),
+						slog.String("stack", string(debug.Stack())))
+				}
+			}()
+
+			next(ctx, update)
+		}
+	}
+}
+
+// Drop updates of users above the limit, denied is called instead of handler
+func RateLimit(limiter Limiter, denied HandlerFunc) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			if user := update.SentFrom(); user != nil && !limiter.Allow(user.ID) {
+				metrics.RateLimited.Add(1)
+				if denied != nil {
+					denied(ctx, update)
+				}
+				return
+			}
+
+			next(ctx, update)
+		}
+	}
+}
+
+// Count updates and handling time by route
+func Metrics() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			start := time.Now()
+			next(ctx, update)
+
+			metrics.Updates.Add(Route(ctx), 1)
+			metrics.UpdatesTime.Add(Route(ctx), time.Since(start).Milliseconds())
+		}
+	}
+}
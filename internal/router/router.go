@@ -0,0 +1,155 @@
+package router
+
+import (
+	"context"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type HandlerFunc func(ctx context.Context, update tgbotapi.Update)
+
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type routeKey struct{}
+
+type command struct {
+	description string
+	handler     HandlerFunc
+}
+
+type callback struct {
+	prefix  string
+	handler HandlerFunc
+}
+
+// Router dispatches updates to handlers registered for commands,
+// reply-button texts, callback data prefixes, locations and inline queries
+type Router struct {
+	botName     string
+	commands    map[string]command
+	order       []string
+	texts       map[string]HandlerFunc
+	callbacks   []callback
+	location    HandlerFunc
+	inline      HandlerFunc
+	fallback    HandlerFunc
+	middlewares []Middleware
+}
+
+func New(botName string) *Router {
+	return &Router{
+		botName:  botName,
+		commands: make(map[string]command),
+		texts:    make(map[string]HandlerFunc),
+	}
+}
+
+// Middlewares wrap every handler, the first one is the outermost
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Command without slash, commands with description are published by setMyCommands
+func (r *Router) Command(name, description string, handler HandlerFunc) {
+	if _, ok := r.commands[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.commands[name] = command{description: description, handler: handler}
+}
+
+// Reply-button action, works in private chats only
+func (r *Router) Text(text string, handler HandlerFunc) {
+	r.texts[text] = handler
+}
+
+// Inline button with callback data starting with prefix
+func (r *Router) Callback(prefix string, handler HandlerFunc) {
+	r.callbacks = append(r.callbacks, callback{prefix: prefix, handler: handler})
+}
+
+func (r *Router) Location(handler HandlerFunc) {
+	r.location = handler
+}
+
+func (r *Router) InlineQuery(handler HandlerFunc) {
+	r.inline = handler
+}
+
+// Any other message
+func (r *Router) Fallback(handler HandlerFunc) {
+	r.fallback = handler
+}
+
+// Commands for setMyCommands
+func (r *Router) BotCommands() []tgbotapi.BotCommand {
+	var commands []tgbotapi.BotCommand
+	for _, name := range r.order {
+		if description := r.commands[name].description; description != "" {
+			commands = append(commands, tgbotapi.BotCommand{Command: name, Description: description})
+		}
+	}
+
+	return commands
+}
+
+// Dispatch update through middlewares
+func (r *Router) Handle(ctx context.Context, update tgbotapi.Update) {
+	route, handler := r.match(update)
+	if handler == nil {
+		return
+	}
+
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+
+	handler(context.WithValue(ctx, routeKey{}, route), update)
+}
+
+// Route name of the update being handled, e.g. "command:weather"
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+func (r *Router) match(update tgbotapi.Update) (string, HandlerFunc) {
+	switch {
+	case update.InlineQuery != nil:
+		return "inline", r.inline
+	case update.CallbackQuery != nil:
+		for _, c := range r.callbacks {
+			if strings.HasPrefix(update.CallbackQuery.Data, c.prefix) {
+				return "callback:" + c.prefix, c.handler
+			}
+		}
+		return "", nil
+	case update.Message == nil || update.Message.From == nil:
+		return "", nil
+	}
+
+	message := update.Message
+
+	if message.IsCommand() {
+		// /cmd@otherbot is not for us
+		if _, bot, ok := strings.Cut(message.CommandWithAt(), "@"); ok && !strings.EqualFold(bot, r.botName) {
+			return "", nil
+		}
+		if c, ok := r.commands[message.Command()]; ok {
+			return "command:" + message.Command(), c.handler
+		}
+		return "", nil
+	}
+
+	if message.Location != nil {
+		return "location", r.location
+	}
+
+	if message.Chat.IsPrivate() {
+		if handler, ok := r.texts[message.Text]; ok {
+			return "text:" + message.Text, handler
+		}
+	}
+
+	return "fallback", r.fallback
+}
@@ -0,0 +1,193 @@
+package router
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func message(chatID int64, text string) *tgbotapi.Message {
+	msg := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: 1},
+		Chat: &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Text: text,
+	}
+	if chatID < 0 {
+		msg.Chat.Type = "group"
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(command)}}
+	}
+
+	return msg
+}
+
+// Router recording the route of handled update, hourly: is registered before hour:
+func newRouter(handled *[]string) *Router {
+	r := New("weatherbot")
+
+	record := func(name string) HandlerFunc {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			*handled = append(*handled, name+"|"+Route(ctx))
+		}
+	}
+
+	r.Command("start", "Начать", record("start"))
+	r.Command("ban", "", record("ban"))
+	r.Text("Прогноз", record("forecast button"))
+	r.Callback("hourly:", record("hourly"))
+	r.Callback("hour", record("hour"))
+	r.Location(record("location"))
+	r.InlineQuery(record("inline"))
+	r.Fallback(record("fallback"))
+
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		update tgbotapi.Update
+		want   string // empty if update is dropped
+	}{
+		{name: "command", update: tgbotapi.Update{Message: message(1, "/start")}, want: "start|command:start"},
+		{name: "command with args", update: tgbotapi.Update{Message: message(1, "/start now")}, want: "start|command:start"},
+		{name: "command to the bot", update: tgbotapi.Update{Message: message(-1, "/start@WeatherBot")}, want: "start|command:start"},
+		{name: "command to other bot", update: tgbotapi.Update{Message: message(-1, "/start@otherbot")}},
+		{name: "unknown command", update: tgbotapi.Update{Message: message(1, "/unknown")}},
+		{name: "hidden command", update: tgbotapi.Update{Message: message(1, "/ban 5")}, want: "ban|command:ban"},
+		{name: "button text", update: tgbotapi.Update{Message: message(1, "Прогноз")}, want: "forecast button|text:Прогноз"},
+		{name: "button text in group", update: tgbotapi.Update{Message: message(-1, "Прогноз")}, want: "fallback|fallback"},
+		{name: "other text", update: tgbotapi.Update{Message: message(1, "Казань")}, want: "fallback|fallback"},
+		{name: "location", update: tgbotapi.Update{Message: func() *tgbotapi.Message {
+			msg := message(1, "")
+			msg.Location = &tgbotapi.Location{Latitude: 55.75, Longitude: 37.62}
+			return msg
+		}()}, want: "location|location"},
+		{name: "first matching callback", update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "hourly:2"}}, want: "hourly|callback:hourly:"},
+		{name: "shorter callback prefix", update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "hour:1"}}, want: "hour|callback:hour"},
+		{name: "unknown callback", update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "noop"}}},
+		{name: "inline query", update: tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{Query: "Казань"}}, want: "inline|inline"},
+		{name: "message without sender", update: tgbotapi.Update{Message: &tgbotapi.Message{Text: "Казань"}}},
+		{name: "channel post", update: tgbotapi.Update{ChannelPost: message(-1, "Казань")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []string
+			newRouter(&handled).Handle(context.Background(), tt.update)
+
+			switch {
+			case tt.want == "" && len(handled) != 0:
+				t.Errorf("update must be dropped, handled by %v", handled)
+			case tt.want != "" && !slices.Equal(handled, []string{tt.want}):
+				t.Errorf("got %v, want %s", handled, tt.want)
+			}
+		})
+	}
+}
+
+func TestBotCommands(t *testing.T) {
+	var handled []string
+	r := newRouter(&handled)
+	r.Command("help", "Помощь", nil)
+	// Re-registered command keeps its place
+	r.Command("start", "Начать заново", nil)
+
+	var got []string
+	for _, command := range r.BotCommands() {
+		got = append(got, command.Command+" "+command.Description)
+	}
+	if want := []string{"start Начать заново", "help Помощь"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, update tgbotapi.Update) {
+				calls = append(calls, name+" before")
+				next(ctx, update)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+
+	r := New("weatherbot")
+	r.Use(trace("outer"))
+	r.Use(trace("inner"))
+	r.Fallback(func(ctx context.Context, update tgbotapi.Update) {
+		calls = append(calls, "handler")
+	})
+
+	// Dropped updates don't reach middlewares
+	r.Handle(context.Background(), tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "x"}})
+	r.Handle(context.Background(), tgbotapi.Update{Message: message(1, "Казань")})
+
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("got %v, want %v", calls, want)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var after bool
+
+	r := New("weatherbot")
+	r.Use(
+		func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, update tgbotapi.Update) {
+				next(ctx, update)
+				after = true
+			}
+		},
+		Recovery(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	r.Fallback(func(ctx context.Context, update tgbotapi.Update) {
+		var settings map[string]string
+		settings["city"] = "Казань"
+	})
+
+	r.Handle(context.Background(), tgbotapi.Update{Message: message(1, "Казань")})
+
+	if !after {
+		t.Error("panic is not recovered")
+	}
+}
+
+type fakeLimiter map[int64]bool
+
+func (l fakeLimiter) Allow(key int64) bool {
+	return l[key]
+}
+
+func TestRateLimit(t *testing.T) {
+	var handled, denied int
+
+	r := New("weatherbot")
+	r.Use(RateLimit(fakeLimiter{1: true}, func(ctx context.Context, update tgbotapi.Update) {
+		denied++
+	}))
+	r.Fallback(func(ctx context.Context, update tgbotapi.Update) {
+		handled++
+	})
+
+	allowed := message(1, "Казань")
+	limited := message(2, "Казань")
+	limited.From.ID = 2
+
+	r.Handle(context.Background(), tgbotapi.Update{Message: allowed})
+	r.Handle(context.Background(), tgbotapi.Update{Message: limited})
+
+	if handled != 1 || denied != 1 {
+		t.Errorf("handled %d, denied %d, want 1 and 1", handled, denied)
+	}
+}
@@ -0,0 +1,346 @@
+package weatherservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/lib/metrics"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+var (
+	ErrNotFound    = errors.New("location not found")
+	ErrUnavailable = errors.New("weather provider is unavailable")
+	ErrRateLimited = errors.New("provider calls are limited")
+)
+
+// Weather provider, returns openweather.ErrNotFound if geocoding found nothing
+type Provider interface {
+	Coordinates(city string) (*models.Cordinates, error)
+	Locations(city string, limit int) ([]models.CordinatesResponse, error)
+	CurrentWeather(lat, lon float64) (*models.Weather, error)
+	ForecastWeather(lat, lon float64) (*[]models.Weather, error)
+}
+
+type Cache interface {
+	GetWeather(ctx context.Context, city string) (*models.CacheWeather, error)
+	UpdateWeather(ctx context.Context, weather models.CacheWeather) error
+	GetForecast(ctx context.Context, city string) (*models.CacheForecast, error)
+	UpdateForecast(ctx context.Context, forecast models.CacheForecast) error
+}
+
+// Recorder of fetched weather
+type Observations interface {
+	Record(ctx context.Context, weather models.CacheWeather)
+}
+
+// Recorder of fetched forecasts
+type Forecasts interface {
+	RecordForecast(ctx context.Context, provider string, location models.CordinatesResponse, issued time.Time, items []models.Weather)
+}
+
+type Settings interface {
+	ChatSettings(ctx context.Context, chatID int64) (*storage.ChatSettings, error)
+}
+
+// Checks if weather provider may be called the given number of times
+type AllowFunc func(calls int) error
+
+// Location of request: known location or city name to geocode
+type Query struct {
+	City     string
+	Location *models.CordinatesResponse // cached by name, by rounded coordinates without name
+	Allow    AllowFunc                  // nil is unlimited
+	NoRecord bool                       // weather isn't kept as observation or prediction, e.g. inline previews
+}
+
+type Current struct {
+	Location  models.CordinatesResponse
+	Weather   models.Weather
+	UpdatedAt time.Time
+	Cached    bool
+}
+
+type Forecast struct {
+	Location models.CordinatesResponse
+	Items    []models.Weather // 3-hour slots
+	Cached   bool
+}
+
+// Weather for bot, API and CLI: cache, provider fallback and chat locations
+type WeatherService struct {
+	log          *slog.Logger
+	provider     Provider
+	cache        Cache
+	observations Observations
+	forecasts    Forecasts
+	settings     Settings
+
+	mu        sync.RWMutex
+	locations map[int64]models.CordinatesResponse
+}
+
+func New(
+	log *slog.Logger, provider Provider, cache Cache,
+	observations Observations, forecasts Forecasts, settings Settings,
+) *WeatherService {
+	return &WeatherService{
+		log:          log,
+		provider:     provider,
+		cache:        cache,
+		observations: observations,
+		forecasts:    forecasts,
+		settings:     settings,
+		locations:    make(map[int64]models.CordinatesResponse),
+	}
+}
+
+// Current weather from cache, on miss from provider
+func (ws *WeatherService) Current(ctx context.Context, query Query) (*Current, error) {
+	op := "services.weatherservice.current"
+
+	key := cacheKey(query)
+	if cacheWeather, err := ws.cache.GetWeather(ctx, key); err == nil {
+		metrics.CacheHits.Add(1)
+		ws.log.Debug("getting weather from cache", slog.String("city", key))
+		location := models.CordinatesResponse{Name: cacheWeather.City, Lat: cacheWeather.Lat, Lon: cacheWeather.Lon}
+		if query.Location != nil {
+			location = *query.Location
+		}
+		return &Current{
+			Location:  location,
+			Weather:   cacheWeather.Weather,
+			UpdatedAt: cacheWeather.UpdatedAt,
+			Cached:    true,
+		}, nil
+	}
+	metrics.CacheMisses.Add(1)
+
+	if err := allow(query, 1); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	location, err := ws.locate(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	current, err := ws.fetch(ctx, location, key, query.NoRecord)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return current, nil
+}
+
+// Current weather from provider regardless of cache
+func (ws *WeatherService) Refresh(ctx context.Context, query Query) (*Current, error) {
+	op := "services.weatherservice.refresh"
+
+	if err := allow(query, 1); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	location, err := ws.locate(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	current, err := ws.fetch(ctx, location, cacheKey(query), query.NoRecord)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return current, nil
+}
+
+// Forecast for 5 days from cache, on miss from provider.
+// Fetched forecast is recorded to be compared with observations
+func (ws *WeatherService) Forecast(ctx context.Context, query Query) (*Forecast, error) {
+	op := "services.weatherservice.forecast"
+
+	key := cacheKey(query)
+	if cached, err := ws.cache.GetForecast(ctx, key); err == nil {
+		ws.log.Debug("getting forecast from cache", slog.String("city", key))
+		location := models.CordinatesResponse{Name: cached.City, Lat: cached.Lat, Lon: cached.Lon}
+		if query.Location != nil {
+			location = *query.Location
+		}
+		return &Forecast{Location: location, Items: cached.Items, Cached: true}, nil
+	}
+
+	if err := allow(query, 1); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	location, err := ws.locate(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, err := ws.provider.ForecastWeather(location.Lat, location.Lon)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+	if location.Name != "" && !query.NoRecord {
+		ws.forecasts.RecordForecast(ctx, openweather.Provider, location, time.Now(), *items)
+	}
+	if err := ws.cache.UpdateForecast(ctx, models.CacheForecast{
+		City:  key,
+		Lat:   location.Lat,
+		Lon:   location.Lon,
+		Items: *items,
+	}); err != nil {
+		ws.log.Error(err.Error())
+	}
+
+	return &Forecast{Location: location, Items: *items}, nil
+}
+
+// Coordinates of query, city is geocoded
+func (ws *WeatherService) Locate(ctx context.Context, query Query) (models.CordinatesResponse, error) {
+	op := "services.weatherservice.locate"
+
+	if err := allow(query, 0); err != nil {
+		return models.CordinatesResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+	location, err := ws.locate(query)
+	if err != nil {
+		return models.CordinatesResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return location, nil
+}
+
+// Geocoding candidates for the city name
+func (ws *WeatherService) Candidates(ctx context.Context, city string, limit int, allowFunc AllowFunc) ([]models.CordinatesResponse, error) {
+	op := "services.weatherservice.candidates"
+
+	if err := allow(Query{Allow: allowFunc}, 1); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	locations, err := ws.provider.Locations(city, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, providerError(err))
+	}
+
+	return locations, nil
+}
+
+// Remember last requested location of chat
+func (ws *WeatherService) SetLocation(chatID int64, location models.CordinatesResponse) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.locations[chatID] = location
+}
+
+// Location of chat: the last requested one, else the city from chat settings
+// (in groups it's set by /setlocation)
+func (ws *WeatherService) Location(ctx context.Context, chatID int64) (models.CordinatesResponse, bool) {
+	ws.mu.RLock()
+	location, ok := ws.locations[chatID]
+	ws.mu.RUnlock()
+	if ok {
+		return location, true
+	}
+
+	return ws.settingsLocation(ctx, chatID)
+}
+
+func (ws *WeatherService) settingsLocation(ctx context.Context, chatID int64) (models.CordinatesResponse, bool) {
+	settings, err := ws.settings.ChatSettings(ctx, chatID)
+	if err != nil {
+		ws.log.Error(err.Error())
+		return models.CordinatesResponse{}, false
+	}
+	if settings.City == "" {
+		return models.CordinatesResponse{}, false
+	}
+
+	return models.CordinatesResponse{
+		Name: settings.City,
+		Lat:  settings.Lat,
+		Lon:  settings.Lon,
+	}, true
+}
+
+// Known location or geocoded city, provider calls must be allowed before
+func (ws *WeatherService) locate(query Query) (models.CordinatesResponse, error) {
+	if query.Location != nil {
+		return *query.Location, nil
+	}
+
+	cord, err := ws.provider.Coordinates(query.City)
+	if err != nil {
+		return models.CordinatesResponse{}, providerError(err)
+	}
+
+	return models.CordinatesResponse{Name: query.City, Lat: cord.Lat, Lon: cord.Lon}, nil
+}
+
+// Request weather from provider, record it unless noRecord and keep in cache under key
+func (ws *WeatherService) fetch(ctx context.Context, location models.CordinatesResponse, key string, noRecord bool) (*Current, error) {
+	weather, err := ws.provider.CurrentWeather(location.Lat, location.Lon)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	cacheWeather := models.CacheWeather{
+		City:      key,
+		Lat:       location.Lat,
+		Lon:       location.Lon,
+		Weather:   *weather,
+		UpdatedAt: time.Now(),
+	}
+	if !noRecord {
+		ws.observations.Record(ctx, cacheWeather)
+	}
+	if err := ws.cache.UpdateWeather(ctx, cacheWeather); err != nil {
+		ws.log.Error(err.Error())
+	}
+
+	return &Current{
+		Location:  location,
+		Weather:   *weather,
+		UpdatedAt: cacheWeather.UpdatedAt,
+	}, nil
+}
+
+// Geocoding costs one more call
+func allow(query Query, calls int) error {
+	if query.Location == nil && query.City != "" {
+		calls++
+	}
+	if query.Allow == nil || calls == 0 {
+		return nil
+	}
+
+	if err := query.Allow(calls); err != nil {
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+
+	return nil
+}
+
+func cacheKey(query Query) string {
+	switch {
+	case query.Location == nil:
+		return query.City
+	case query.Location.Name != "":
+		return query.Location.Name
+	}
+
+	return fmt.Sprintf("%.2f,%.2f", query.Location.Lat, query.Location.Lon)
+}
+
+func providerError(err error) error {
+	if errors.Is(err, openweather.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
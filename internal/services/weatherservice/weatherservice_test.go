@@ -0,0 +1,374 @@
+package weatherservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/clients/openweather"
+	"github.com/m1al04949/weatherbot/internal/lib/ratelimit"
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+var errDown = errors.New("connection refused")
+
+// Fake provider knows one city, err fails every weather request
+type fakeProvider struct {
+	err   error
+	calls []string
+}
+
+func (p *fakeProvider) Coordinates(city string) (*models.Cordinates, error) {
+	p.calls = append(p.calls, "coordinates")
+	if city != "Казань" {
+		return nil, fmt.Errorf("geocoding: %w", openweather.ErrNotFound)
+	}
+	return &models.Cordinates{Lat: 55.79, Lon: 49.12}, nil
+}
+
+func (p *fakeProvider) Locations(city string, limit int) ([]models.CordinatesResponse, error) {
+	p.calls = append(p.calls, "locations")
+	return []models.CordinatesResponse{{Name: city, Lat: 55.79, Lon: 49.12}}, nil
+}
+
+func (p *fakeProvider) CurrentWeather(lat, lon float64) (*models.Weather, error) {
+	p.calls = append(p.calls, "current")
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &models.Weather{Temp: lat, Description: "ясно"}, nil
+}
+
+func (p *fakeProvider) ForecastWeather(lat, lon float64) (*[]models.Weather, error) {
+	p.calls = append(p.calls, "forecast")
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &[]models.Weather{{Temp: 1}, {Temp: 2}}, nil
+}
+
+type fakeCache struct {
+	weather   map[string]models.CacheWeather
+	forecasts map[string]models.CacheForecast
+}
+
+func (c *fakeCache) GetWeather(ctx context.Context, city string) (*models.CacheWeather, error) {
+	weather, ok := c.weather[city]
+	if !ok {
+		return nil, errors.New("key is not exists")
+	}
+	return &weather, nil
+}
+
+func (c *fakeCache) UpdateWeather(ctx context.Context, weather models.CacheWeather) error {
+	c.weather[weather.City] = weather
+	return nil
+}
+
+func (c *fakeCache) GetForecast(ctx context.Context, city string) (*models.CacheForecast, error) {
+	forecast, ok := c.forecasts[city]
+	if !ok {
+		return nil, errors.New("key is not exists")
+	}
+	return &forecast, nil
+}
+
+func (c *fakeCache) UpdateForecast(ctx context.Context, forecast models.CacheForecast) error {
+	c.forecasts[forecast.City] = forecast
+	return nil
+}
+
+type fakeRecorder struct {
+	observations []models.CacheWeather
+	forecasts    []string
+}
+
+func (r *fakeRecorder) Record(ctx context.Context, weather models.CacheWeather) {
+	r.observations = append(r.observations, weather)
+}
+
+func (r *fakeRecorder) RecordForecast(
+	ctx context.Context, provider string, location models.CordinatesResponse, issued time.Time, items []models.Weather,
+) {
+	r.forecasts = append(r.forecasts, fmt.Sprintf("%s:%s:%d", provider, location.Name, len(items)))
+}
+
+type fakeSettings map[int64]storage.ChatSettings
+
+func (s fakeSettings) ChatSettings(ctx context.Context, chatID int64) (*storage.ChatSettings, error) {
+	settings := s[chatID]
+	settings.ChatID = chatID
+	return &settings, nil
+}
+
+type fixture struct {
+	service  *WeatherService
+	provider *fakeProvider
+	cache    *fakeCache
+	recorder *fakeRecorder
+}
+
+func newFixture() fixture {
+	f := fixture{
+		provider: &fakeProvider{},
+		cache:    &fakeCache{weather: map[string]models.CacheWeather{}, forecasts: map[string]models.CacheForecast{}},
+		recorder: &fakeRecorder{},
+	}
+	settings := fakeSettings{-100: {City: "Орск", Lat: 51.2, Lon: 58.6}}
+	f.service = New(slog.New(slog.NewTextHandler(io.Discard, nil)), f.provider, f.cache, f.recorder, f.recorder, settings)
+
+	return f
+}
+
+// Allow func counting requested calls
+func counting(total *int, err error) AllowFunc {
+	return func(calls int) error {
+		*total += calls
+		return err
+	}
+}
+
+func TestCurrentFromCache(t *testing.T) {
+	f := newFixture()
+	updated := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	f.cache.weather["Москва"] = models.CacheWeather{City: "Москва", Lat: 55.75, Lon: 37.62, Weather: models.Weather{Temp: 7}, UpdatedAt: updated}
+
+	var calls int
+	current, err := f.service.Current(context.Background(), Query{City: "Москва", Allow: counting(&calls, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !current.Cached || current.Weather.Temp != 7 || !current.UpdatedAt.Equal(updated) {
+		t.Errorf("got %+v", current)
+	}
+	if current.Location.Name != "Москва" || current.Location.Lat != 55.75 {
+		t.Errorf("location: got %+v", current.Location)
+	}
+	if calls != 0 || len(f.provider.calls) != 0 {
+		t.Errorf("provider must not be called: allowed %d, calls %v", calls, f.provider.calls)
+	}
+}
+
+func TestCurrentFromProvider(t *testing.T) {
+	f := newFixture()
+
+	var calls int
+	current, err := f.service.Current(context.Background(), Query{City: "Казань", Allow: counting(&calls, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current.Cached || current.Location.Name != "Казань" || current.Weather.Temp != 55.79 {
+		t.Errorf("got %+v", current)
+	}
+	if calls != 2 {
+		t.Errorf("allowed calls: got %d, want 2", calls)
+	}
+	if cached, ok := f.cache.weather["Казань"]; !ok || cached.Lat != 55.79 {
+		t.Errorf("weather is not cached: %+v", f.cache.weather)
+	}
+	if len(f.recorder.observations) != 1 {
+		t.Errorf("observations: got %d, want 1", len(f.recorder.observations))
+	}
+
+	// Second request is served from cache
+	if _, err := f.service.Current(context.Background(), Query{City: "Казань"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.provider.calls) != 2 {
+		t.Errorf("provider calls: got %v", f.provider.calls)
+	}
+
+	// Previews are cached but not recorded
+	if _, err := f.service.Refresh(context.Background(), Query{City: "Казань", NoRecord: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.recorder.observations) != 1 {
+		t.Errorf("observations: got %d, want 1", len(f.recorder.observations))
+	}
+}
+
+func TestCurrentByCoordinates(t *testing.T) {
+	f := newFixture()
+
+	location := models.CordinatesResponse{Lat: 51.234, Lon: 58.567}
+	var calls int
+	current, err := f.service.Current(context.Background(), Query{Location: &location, Allow: counting(&calls, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("allowed calls: got %d, want 1", calls)
+	}
+	if current.Location.Name != "" {
+		t.Errorf("name: got %q, want empty", current.Location.Name)
+	}
+	if _, ok := f.cache.weather["51.23,58.57"]; !ok {
+		t.Errorf("weather is not cached by coordinates: %v", f.cache.weather)
+	}
+}
+
+func TestCurrentErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		city        string
+		providerErr error
+		allowErr    error
+		want        error
+		calls       int
+	}{
+		{name: "not found", city: "Нигде", want: ErrNotFound, calls: 1},
+		{name: "upstream down", city: "Казань", providerErr: errDown, want: ErrUnavailable, calls: 2},
+		{name: "rate limited", city: "Казань", allowErr: ratelimit.ErrTooOften, want: ErrRateLimited, calls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.provider.err = tt.providerErr
+
+			var allowed int
+			_, err := f.service.Current(context.Background(), Query{City: tt.city, Allow: counting(&allowed, tt.allowErr)})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if len(f.provider.calls) != tt.calls {
+				t.Errorf("provider calls: got %v, want %d", f.provider.calls, tt.calls)
+			}
+			if len(f.cache.weather) != 0 {
+				t.Errorf("nothing must be cached: %v", f.cache.weather)
+			}
+		})
+	}
+
+	// Cause is kept for callers which need details
+	f := newFixture()
+	_, err := f.service.Current(context.Background(), Query{City: "Казань", Allow: counting(new(int), ratelimit.ErrBudgetSpent)})
+	if !errors.Is(err, ratelimit.ErrBudgetSpent) {
+		t.Errorf("got %v, want budget error", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	f := newFixture()
+	f.cache.weather["Казань"] = models.CacheWeather{City: "Казань", Weather: models.Weather{Temp: -30}}
+
+	current, err := f.service.Refresh(context.Background(), Query{City: "Казань"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current.Cached || f.cache.weather["Казань"].Weather.Temp != 55.79 {
+		t.Errorf("cache is not refreshed: %+v", f.cache.weather["Казань"])
+	}
+}
+
+func TestForecast(t *testing.T) {
+	f := newFixture()
+
+	var calls int
+	result, err := f.service.Forecast(context.Background(), Query{City: "Казань", Allow: counting(&calls, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Cached || len(result.Items) != 2 || result.Location.Name != "Казань" {
+		t.Errorf("got %+v", result)
+	}
+	if calls != 2 {
+		t.Errorf("allowed calls: got %d, want 2", calls)
+	}
+	if len(f.recorder.forecasts) != 1 || f.recorder.forecasts[0] != "openweather:Казань:2" {
+		t.Errorf("recorded forecasts: got %v", f.recorder.forecasts)
+	}
+	if cached, ok := f.cache.forecasts["Казань"]; !ok || cached.Lat != 55.79 || len(cached.Items) != 2 {
+		t.Errorf("forecast is not cached: %+v", f.cache.forecasts)
+	}
+
+	// Second request is served from cache without provider calls
+	calls = 0
+	result, err = f.service.Forecast(context.Background(), Query{City: "Казань", Allow: counting(&calls, nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Cached || len(result.Items) != 2 || result.Location.Name != "Казань" || result.Location.Lat != 55.79 {
+		t.Errorf("got %+v", result)
+	}
+	if calls != 0 || len(f.provider.calls) != 2 {
+		t.Errorf("provider must not be called: allowed %d, calls %v", calls, f.provider.calls)
+	}
+	if len(f.recorder.forecasts) != 1 {
+		t.Errorf("recorded forecasts: got %v", f.recorder.forecasts)
+	}
+
+	// Coordinates without name are not recorded, but cached by coordinates
+	_, err = f.service.Forecast(context.Background(), Query{Location: &models.CordinatesResponse{Lat: 1, Lon: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.recorder.forecasts) != 1 {
+		t.Errorf("recorded forecasts: got %v", f.recorder.forecasts)
+	}
+	if _, ok := f.cache.forecasts["1.00,2.00"]; !ok {
+		t.Errorf("forecast is not cached by coordinates: %v", f.cache.forecasts)
+	}
+
+	// Previews are not recorded
+	_, err = f.service.Forecast(context.Background(), Query{
+		Location: &models.CordinatesResponse{Name: "Орск", Lat: 51.2, Lon: 58.6},
+		NoRecord: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.recorder.forecasts) != 1 {
+		t.Errorf("recorded forecasts: got %v", f.recorder.forecasts)
+	}
+
+	f.provider.err = errDown
+	if _, err := f.service.Forecast(context.Background(), Query{City: "Казань"}); err != nil {
+		t.Errorf("cached forecast: got %v", err)
+	}
+	if _, err := f.service.Forecast(context.Background(), Query{City: "Уфа"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want %v", err, ErrNotFound)
+	}
+	_, err = f.service.Forecast(context.Background(), Query{Location: &models.CordinatesResponse{Lat: 3, Lon: 4}})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("got %v, want %v", err, ErrUnavailable)
+	}
+	if len(f.cache.forecasts) != 3 {
+		t.Errorf("failed forecasts must not be cached: %v", f.cache.forecasts)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+
+	if _, ok := f.service.Location(ctx, 1); ok {
+		t.Error("unknown chat must have no location")
+	}
+
+	// Group city from settings until another location is requested
+	location, ok := f.service.Location(ctx, -100)
+	if !ok || location.Name != "Орск" {
+		t.Errorf("got %+v, %v", location, ok)
+	}
+
+	// Last requested location wins over settings in groups and private chats,
+	// e.g. "Прогноз" after /weather Казань is for Казань
+	for _, chatID := range []int64{-100, -200, 1} {
+		f.service.SetLocation(chatID, models.CordinatesResponse{Name: "Казань"})
+		location, ok = f.service.Location(ctx, chatID)
+		if !ok || location.Name != "Казань" {
+			t.Errorf("chat %d: got %+v, %v", chatID, location, ok)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+CREATE TABLE users (
+    id          INTEGER PRIMARY KEY,
+    user_name   TEXT NOT NULL DEFAULT '',
+    first_name  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chat_settings (
+    chat_id     INTEGER PRIMARY KEY,
+    language    TEXT NOT NULL,
+    units       TEXT NOT NULL,
+    timezone    TEXT NOT NULL,
+    city        TEXT NOT NULL DEFAULT '',
+    lat         REAL NOT NULL DEFAULT 0,
+    lon         REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE favourites (
+    user_id     INTEGER NOT NULL,
+    city        TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, city)
+);
+
+CREATE TABLE subscriptions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_id     INTEGER NOT NULL,
+    city        TEXT NOT NULL,
+    lat         REAL NOT NULL,
+    lon         REAL NOT NULL,
+    time        TEXT NOT NULL,
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_chat_id ON subscriptions (chat_id);
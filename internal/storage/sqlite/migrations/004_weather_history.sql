@@ -0,0 +1,14 @@
+-- Days observed by the archive, only complete ones: days with missing values are not stored
+CREATE TABLE weather_history (
+    lat             REAL NOT NULL,
+    lon             REAL NOT NULL,
+    date            TEXT NOT NULL,
+    min_temp        REAL NOT NULL,
+    max_temp        REAL NOT NULL,
+    code            INTEGER NOT NULL,
+    description     TEXT NOT NULL,
+    precipitation   REAL NOT NULL,
+    max_speed       REAL NOT NULL,
+    fetched_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (lat, lon, date)
+);
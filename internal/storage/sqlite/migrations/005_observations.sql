@@ -0,0 +1,22 @@
+CREATE TABLE observations (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    city            TEXT NOT NULL,
+    lat             REAL NOT NULL,
+    lon             REAL NOT NULL,
+    cell_lat        INTEGER NOT NULL,
+    cell_lon        INTEGER NOT NULL,
+    tz_offset       INTEGER NOT NULL,
+    observed_at     INTEGER NOT NULL,
+    period          INTEGER NOT NULL,
+    temp            REAL NOT NULL,
+    min_temp        REAL NOT NULL,
+    max_temp        REAL NOT NULL,
+    humidity        REAL NOT NULL,
+    speed           REAL NOT NULL,
+    code            INTEGER NOT NULL DEFAULT 0,
+    description     TEXT NOT NULL DEFAULT '',
+    -- Series are kept by place: cells are coordinates rounded to 0.01°, about 1 km
+    UNIQUE (cell_lat, cell_lon, observed_at, period)
+);
+
+CREATE INDEX observations_period_observed_at ON observations (period, observed_at);
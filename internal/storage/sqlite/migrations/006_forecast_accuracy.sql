@@ -0,0 +1,27 @@
+-- Predictions are matched with observations by place, not by the name it was requested with
+CREATE TABLE predictions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider    TEXT NOT NULL,
+    city        TEXT NOT NULL,
+    cell_lat    INTEGER NOT NULL,
+    cell_lon    INTEGER NOT NULL,
+    issued_at   INTEGER NOT NULL,
+    target_at   INTEGER NOT NULL,
+    lead_hours  INTEGER NOT NULL,
+    temp        REAL NOT NULL,
+    speed       REAL NOT NULL,
+    UNIQUE (provider, cell_lat, cell_lon, target_at, lead_hours)
+);
+
+CREATE INDEX predictions_target_at ON predictions (target_at);
+
+CREATE TABLE forecast_errors (
+    provider        TEXT NOT NULL,
+    lead_hours      INTEGER NOT NULL,
+    count           INTEGER NOT NULL,
+    temp_abs_sum    REAL NOT NULL,
+    temp_sq_sum     REAL NOT NULL,
+    temp_sum        REAL NOT NULL,
+    speed_abs_sum   REAL NOT NULL,
+    PRIMARY KEY (provider, lead_hours)
+);
@@ -0,0 +1,798 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+	"github.com/m1al04949/weatherbot/internal/storage"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const dateLayout = "2006-01-02"
+
+type Storage struct {
+	db  *sql.DB
+	log *slog.Logger
+}
+
+var _ storage.Repository = (*Storage)(nil)
+
+func New(path string, log *slog.Logger) (*Storage, error) {
+	op := "storage.sqlite.new"
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)", path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// SQLite allows only one writer
+	db.SetMaxOpenConns(1)
+
+	s := &Storage{db: db, log: log}
+	if err := s.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Apply embedded migrations which are not applied yet
+func (s *Storage) migrate(ctx context.Context) error {
+	op := "storage.sqlite.migrate"
+
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
+		)`); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := filepath.Base(name)
+
+		var applied int
+		if err := s.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		query, err := migrations.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %s: %w", op, version, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		s.log.Info("migration applied", slog.String("version", version))
+	}
+
+	return nil
+}
+
+// Create or update user
+func (s *Storage) SaveUser(ctx context.Context, user storage.User) error {
+	op := "storage.sqlite.saveuser"
+
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO users (id, user_name, first_name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			user_name = excluded.user_name,
+			first_name = excluded.first_name,
+			updated_at = excluded.updated_at`,
+		user.ID, user.UserName, user.FirstName, now, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) User(ctx context.Context, id int64) (*storage.User, error) {
+	op := "storage.sqlite.user"
+
+	var user storage.User
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_name, first_name, created_at, updated_at FROM users WHERE id = ?`, id).
+		Scan(&user.ID, &user.UserName, &user.FirstName, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &user, nil
+}
+
+func (s *Storage) CountUsers(ctx context.Context) (int, error) {
+	op := "storage.sqlite.countusers"
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// IDs of all known users, private chat ID is the same as user ID
+func (s *Storage) UserIDs(ctx context.Context) ([]int64, error) {
+	op := "storage.sqlite.userids"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+func (s *Storage) SaveChatSettings(ctx context.Context, settings storage.ChatSettings) error {
+	op := "storage.sqlite.savechatsettings"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO chat_settings (chat_id, language, units, timezone, city, lat, lon)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET
+			language = excluded.language,
+			units = excluded.units,
+			timezone = excluded.timezone,
+			city = excluded.city,
+			lat = excluded.lat,
+			lon = excluded.lon`,
+		settings.ChatID, settings.Language, settings.Units, settings.Timezone,
+		settings.City, settings.Lat, settings.Lon)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Saved chat settings or defaults
+func (s *Storage) ChatSettings(ctx context.Context, chatID int64) (*storage.ChatSettings, error) {
+	op := "storage.sqlite.chatsettings"
+
+	settings := storage.DefaultChatSettings(chatID)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT language, units, timezone, city, lat, lon FROM chat_settings WHERE chat_id = ?`, chatID).
+		Scan(&settings.Language, &settings.Units, &settings.Timezone, &settings.City, &settings.Lat, &settings.Lon)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &settings, nil
+}
+
+func (s *Storage) AddFavourite(ctx context.Context, userID int64, city string) error {
+	op := "storage.sqlite.addfavourite"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO favourites (user_id, city, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, city) DO NOTHING`,
+		userID, city, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveFavourite(ctx context.Context, userID int64, city string) (bool, error) {
+	op := "storage.sqlite.removefavourite"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM favourites WHERE user_id = ? AND city = ?`, userID, city)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return removed > 0, nil
+}
+
+// User's favourites in order of adding
+func (s *Storage) Favourites(ctx context.Context, userID int64) ([]string, error) {
+	op := "storage.sqlite.favourites"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT city FROM favourites WHERE user_id = ? ORDER BY created_at, rowid`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var cities []string
+	for rows.Next() {
+		var city string
+		if err := rows.Scan(&city); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cities = append(cities, city)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cities, nil
+}
+
+func (s *Storage) AddSubscription(ctx context.Context, subscription storage.Subscription) (int64, error) {
+	op := "storage.sqlite.addsubscription"
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO subscriptions (chat_id, city, lat, lon, time, enabled, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		subscription.ChatID, subscription.City, subscription.Lat, subscription.Lon,
+		subscription.Time, subscription.Enabled, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) RemoveSubscription(ctx context.Context, id int64) error {
+	op := "storage.sqlite.removesubscription"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) Subscriptions(ctx context.Context, chatID int64) ([]storage.Subscription, error) {
+	op := "storage.sqlite.subscriptions"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, chat_id, city, lat, lon, time, enabled, created_at
+		FROM subscriptions WHERE chat_id = ? ORDER BY id`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subscriptions []storage.Subscription
+	for rows.Next() {
+		var sub storage.Subscription
+		if err := rows.Scan(&sub.ID, &sub.ChatID, &sub.City, &sub.Lat, &sub.Lon,
+			&sub.Time, &sub.Enabled, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscriptions, nil
+}
+
+// Chat blocked the bot
+func (s *Storage) DisableSubscriptions(ctx context.Context, chatID int64) error {
+	op := "storage.sqlite.disablesubscriptions"
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE subscriptions SET enabled = FALSE WHERE chat_id = ?`, chatID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) EnqueueMessage(ctx context.Context, message storage.OutboxMessage) (int64, error) {
+	op := "storage.sqlite.enqueuemessage"
+
+	// UTC keeps stored timestamps comparable as text
+	now := time.Now().UTC()
+	next := message.NextAttemptAt.UTC()
+	if message.NextAttemptAt.IsZero() {
+		next = now
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO outbox (chat_id, text, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		message.ChatID, message.Text, message.Attempts, next, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Messages to deliver by now, oldest first
+func (s *Storage) DueMessages(ctx context.Context, now time.Time, limit int) ([]storage.OutboxMessage, error) {
+	op := "storage.sqlite.duemessages"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, chat_id, text, attempts, next_attempt_at, created_at
+		FROM outbox WHERE next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var messages []storage.OutboxMessage
+	for rows.Next() {
+		var m storage.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.Text, &m.Attempts, &m.NextAttemptAt, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
+
+func (s *Storage) RescheduleMessage(ctx context.Context, id int64, attempts int, next time.Time) error {
+	op := "storage.sqlite.reschedulemessage"
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = ?, next_attempt_at = ? WHERE id = ?`, attempts, next.UTC(), id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteMessage(ctx context.Context, id int64) error {
+	op := "storage.sqlite.deletemessage"
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteChatMessages(ctx context.Context, chatID int64) error {
+	op := "storage.sqlite.deletechatmessages"
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE chat_id = ?`, chatID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) HistoryDay(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error) {
+	op := "storage.sqlite.historyday"
+
+	day := models.DailyForecast{
+		Date: time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()),
+	}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT min_temp, max_temp, code, description, precipitation, max_speed
+		FROM weather_history WHERE lat = ? AND lon = ? AND date = ?`,
+		roundCoordinate(lat), roundCoordinate(lon), date.Format(dateLayout)).
+		Scan(&day.MinTemp, &day.MaxTemp, &day.Code, &day.Description, &day.Precipitation, &day.MaxSpeed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &day, nil
+}
+
+func (s *Storage) SaveHistoryDay(ctx context.Context, lat, lon float64, day models.DailyForecast) error {
+	op := "storage.sqlite.savehistoryday"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO weather_history
+			(lat, lon, date, min_temp, max_temp, code, description, precipitation, max_speed, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (lat, lon, date) DO UPDATE SET
+			min_temp = excluded.min_temp,
+			max_temp = excluded.max_temp,
+			code = excluded.code,
+			description = excluded.description,
+			precipitation = excluded.precipitation,
+			max_speed = excluded.max_speed,
+			fetched_at = excluded.fetched_at`,
+		roundCoordinate(lat), roundCoordinate(lon), day.Date.Format(dateLayout),
+		day.MinTemp, day.MaxTemp, day.Code, day.Description, day.Precipitation, day.MaxSpeed, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Duplicates of the same snapshot are ignored
+func (s *Storage) SaveObservation(ctx context.Context, observation storage.Observation) error {
+	op := "storage.sqlite.saveobservation"
+
+	_, offset := observation.ObservedAt.Zone()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO observations (city, lat, lon, cell_lat, cell_lon, tz_offset, observed_at, period,
+			temp, min_temp, max_temp, humidity, speed, code, description)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cell_lat, cell_lon, observed_at, period) DO NOTHING`,
+		observation.City, observation.Lat, observation.Lon, cell(observation.Lat), cell(observation.Lon),
+		offset, observation.ObservedAt.Unix(),
+		int64(observation.Period.Seconds()), observation.Temp, observation.MinTemp, observation.MaxTemp,
+		observation.Humidity, observation.Speed, observation.Code, observation.Description)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Observations of the place since from, oldest first
+func (s *Storage) Observations(ctx context.Context, lat, lon float64, from time.Time) ([]storage.Observation, error) {
+	op := "storage.sqlite.observations"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT city, lat, lon, tz_offset, observed_at, period,
+			temp, min_temp, max_temp, humidity, speed, code, description
+		FROM observations WHERE cell_lat = ? AND cell_lon = ? AND observed_at >= ? ORDER BY observed_at`,
+		cell(lat), cell(lon), from.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var observations []storage.Observation
+	for rows.Next() {
+		var (
+			o                        storage.Observation
+			offset, observed, period int64
+		)
+		if err := rows.Scan(&o.City, &o.Lat, &o.Lon, &offset, &observed, &period,
+			&o.Temp, &o.MinTemp, &o.MaxTemp, &o.Humidity, &o.Speed, &o.Code, &o.Description); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		o.ObservedAt = time.Unix(observed, 0).In(time.FixedZone("", int(offset)))
+		o.Period = time.Duration(period) * time.Second
+		observations = append(observations, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return observations, nil
+}
+
+// Replace observations of period from older than before with means of period to buckets,
+// before must be aligned to period so that every bucket is complete
+func (s *Storage) DownsampleObservations(ctx context.Context, from, to time.Duration, before time.Time) (int64, error) {
+	op := "storage.sqlite.downsampleobservations"
+
+	fromSeconds, toSeconds := int64(from.Seconds()), int64(to.Seconds())
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO observations (city, lat, lon, cell_lat, cell_lon, tz_offset, observed_at, period,
+			temp, min_temp, max_temp, humidity, speed)
+		SELECT MIN(city), AVG(lat), AVG(lon), cell_lat, cell_lon, MAX(tz_offset), (observed_at / ?) * ?, ?,
+			AVG(temp), MIN(min_temp), MAX(max_temp), AVG(humidity), AVG(speed)
+		FROM observations WHERE period = ? AND observed_at < ?
+		GROUP BY cell_lat, cell_lon, observed_at / ?
+		ON CONFLICT (cell_lat, cell_lon, observed_at, period) DO NOTHING`,
+		toSeconds, toSeconds, toSeconds, fromSeconds, before.Unix(), toSeconds)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	buckets, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM observations WHERE period = ? AND observed_at < ?`, fromSeconds, before.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buckets, nil
+}
+
+func (s *Storage) DeleteObservations(ctx context.Context, period time.Duration, before time.Time) (int64, error) {
+	op := "storage.sqlite.deleteobservations"
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM observations WHERE period = ? AND observed_at < ?`, int64(period.Seconds()), before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// Predictions of the same target and lead are saved once
+func (s *Storage) SavePredictions(ctx context.Context, predictions []storage.Prediction) error {
+	op := "storage.sqlite.savepredictions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range predictions {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO predictions (provider, city, cell_lat, cell_lon, issued_at, target_at, lead_hours, temp, speed)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (provider, cell_lat, cell_lon, target_at, lead_hours) DO NOTHING`,
+			p.Provider, p.City, cell(p.Lat), cell(p.Lon), p.IssuedAt.Unix(), p.TargetAt.Unix(),
+			p.LeadHours, p.Temp, p.Speed); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Predictions with target before and a raw observation of the place within window of target
+func (s *Storage) MatchPredictions(ctx context.Context, before time.Time, window time.Duration) ([]storage.PredictionMatch, error) {
+	op := "storage.sqlite.matchpredictions"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, provider, city, cell_lat, cell_lon, issued_at, target_at, lead_hours, temp, speed,
+			observed_temp, observed_speed
+		FROM (
+			-- bare columns are taken from the nearest observation
+			SELECT p.id, p.provider, p.city, p.cell_lat, p.cell_lon, p.issued_at, p.target_at, p.lead_hours,
+				p.temp, p.speed, o.temp AS observed_temp, o.speed AS observed_speed,
+				MIN(ABS(o.observed_at - p.target_at))
+			FROM predictions p
+			JOIN observations o ON o.cell_lat = p.cell_lat AND o.cell_lon = p.cell_lon AND o.period = 0
+				AND o.observed_at BETWEEN p.target_at - ? AND p.target_at + ?
+			WHERE p.target_at < ?
+			GROUP BY p.id)`,
+		int64(window.Seconds()), int64(window.Seconds()), before.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var matches []storage.PredictionMatch
+	for rows.Next() {
+		var (
+			m                storage.PredictionMatch
+			cellLat, cellLon int64
+			issued, target   int64
+		)
+		if err := rows.Scan(&m.ID, &m.Provider, &m.City, &cellLat, &cellLon, &issued, &target, &m.LeadHours,
+			&m.Temp, &m.Speed, &m.ObservedTemp, &m.ObservedSpeed); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		m.Lat, m.Lon = float64(cellLat)/100, float64(cellLon)/100
+		m.IssuedAt = time.Unix(issued, 0)
+		m.TargetAt = time.Unix(target, 0)
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return matches, nil
+}
+
+// Add errors of matched predictions to stats and delete them
+func (s *Storage) ResolvePredictions(ctx context.Context, matches []storage.PredictionMatch) error {
+	op := "storage.sqlite.resolvepredictions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, m := range matches {
+		tempError := m.Temp - m.ObservedTemp
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO forecast_errors (provider, lead_hours, count, temp_abs_sum, temp_sq_sum, temp_sum, speed_abs_sum)
+			VALUES (?, ?, 1, ?, ?, ?, ?)
+			ON CONFLICT (provider, lead_hours) DO UPDATE SET
+				count = count + 1,
+				temp_abs_sum = temp_abs_sum + excluded.temp_abs_sum,
+				temp_sq_sum = temp_sq_sum + excluded.temp_sq_sum,
+				temp_sum = temp_sum + excluded.temp_sum,
+				speed_abs_sum = speed_abs_sum + excluded.speed_abs_sum`,
+			m.Provider, m.LeadHours, math.Abs(tempError), tempError*tempError, tempError,
+			math.Abs(m.Speed-m.ObservedSpeed)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM predictions WHERE id = ?`, m.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Drop predictions which never got an observation
+func (s *Storage) DeletePredictions(ctx context.Context, before time.Time) (int64, error) {
+	op := "storage.sqlite.deletepredictions"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM predictions WHERE target_at < ?`, before.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func (s *Storage) ForecastStats(ctx context.Context) ([]storage.ForecastStats, error) {
+	op := "storage.sqlite.forecaststats"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT provider, lead_hours, count, temp_abs_sum, temp_sq_sum, temp_sum, speed_abs_sum
+		FROM forecast_errors ORDER BY provider, lead_hours`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var stats []storage.ForecastStats
+	for rows.Next() {
+		var (
+			st                        storage.ForecastStats
+			absSum, sqSum, sum, speed float64
+		)
+		if err := rows.Scan(&st.Provider, &st.LeadHours, &st.Count, &absSum, &sqSum, &sum, &speed); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if st.Count > 0 {
+			n := float64(st.Count)
+			st.TempMAE = absSum / n
+			st.TempRMSE = math.Sqrt(sqSum / n)
+			st.TempBias = sum / n
+			st.SpeedMAE = speed / n
+		}
+		stats = append(stats, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) AddAudit(ctx context.Context, entry storage.AuditEntry) error {
+	op := "storage.sqlite.addaudit"
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (admin_id, action, args, created_at) VALUES (?, ?, ?, ?)`,
+		entry.AdminID, entry.Action, entry.Args, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Nearby points of the same city share history, 0.01° is about 1 km
+func roundCoordinate(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Rounded coordinate as integer, so that places are compared exactly
+func cell(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// Shutdown
+func (s *Storage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("error closing storage: %w", err)
+	}
+	s.log.Info("storage closed successfully")
+	return nil
+}
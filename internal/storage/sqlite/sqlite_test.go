@@ -0,0 +1,325 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/storage"
+)
+
+func newStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	s, err := New(filepath.Join(t.TempDir(), "weatherbot.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "weatherbot.db")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := New(path, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(names) {
+		t.Errorf("applied migrations: got %d, want %d", applied, len(names))
+	}
+
+	if err := s.SaveUser(context.Background(), storage.User{ID: 1, UserName: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Reopening applies nothing and keeps data
+	s, err = New(path, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var again int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&again); err != nil {
+		t.Fatal(err)
+	}
+	if again != applied {
+		t.Errorf("applied migrations after reopen: got %d, want %d", again, applied)
+	}
+	if count, err := s.CountUsers(context.Background()); err != nil || count != 1 {
+		t.Errorf("users after reopen: got %d, %v", count, err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	if _, err := s.User(ctx, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("got %v, want %v", err, storage.ErrNotFound)
+	}
+
+	for _, user := range []storage.User{
+		{ID: 2, UserName: "second"},
+		{ID: 1, UserName: "old", FirstName: "Анна"},
+		{ID: 1, UserName: "new", FirstName: "Анна"},
+	} {
+		if err := s.SaveUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	user, err := s.User(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UserName != "new" || user.FirstName != "Анна" || user.CreatedAt.IsZero() {
+		t.Errorf("got %+v", user)
+	}
+
+	ids, err := s.UserIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("ids: got %v", ids)
+	}
+}
+
+func TestChatSettings(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	settings, err := s.ChatSettings(ctx, -100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *settings != storage.DefaultChatSettings(-100) {
+		t.Errorf("defaults: got %+v", settings)
+	}
+
+	want := storage.ChatSettings{
+		ChatID: -100, Language: "ru", Units: "metric", Timezone: "Asia/Yekaterinburg",
+		City: "Орск", Lat: 51.2, Lon: 58.6,
+	}
+	if err := s.SaveChatSettings(ctx, storage.DefaultChatSettings(-100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveChatSettings(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err = s.ChatSettings(ctx, -100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *settings != want {
+		t.Errorf("got %+v, want %+v", settings, want)
+	}
+}
+
+func TestFavourites(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	for _, city := range []string{"Орск", "Казань", "Орск", "Москва"} {
+		if err := s.AddFavourite(ctx, 1, city); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddFavourite(ctx, 2, "Сочи"); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.RemoveFavourite(ctx, 1, "Казань")
+	if err != nil || !removed {
+		t.Fatalf("remove: got %v, %v", removed, err)
+	}
+	if removed, err := s.RemoveFavourite(ctx, 1, "Казань"); err != nil || removed {
+		t.Errorf("second remove: got %v, %v", removed, err)
+	}
+
+	cities, err := s.Favourites(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cities, []string{"Орск", "Москва"}) {
+		t.Errorf("got %v, want cities in order of adding without duplicates", cities)
+	}
+}
+
+func TestOutboxMessages(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	first, err := s.EnqueueMessage(ctx, storage.OutboxMessage{ChatID: 1, Text: "первое"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := s.EnqueueMessage(ctx, storage.OutboxMessage{ChatID: 2, Text: "позже", NextAttemptAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnqueueMessage(ctx, storage.OutboxMessage{ChatID: 1, Text: "второе"}); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := s.DueMessages(ctx, now.Add(time.Second), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].ID != first || due[0].Text != "первое" || due[1].Text != "второе" {
+		t.Fatalf("due: got %+v", due)
+	}
+
+	if err := s.RescheduleMessage(ctx, first, 1, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	due, err = s.DueMessages(ctx, now.Add(90*time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Text != "второе" || due[1].ID != later {
+		t.Errorf("due after reschedule: got %+v", due)
+	}
+
+	if err := s.DeleteChatMessages(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteMessage(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	due, err = s.DueMessages(ctx, now.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 0 {
+		t.Errorf("queue must be empty: %+v", due)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+
+	id, err := s.AddSubscription(ctx, storage.Subscription{ChatID: 1, City: "Орск", Time: "08:00", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisableSubscriptions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptions, err := s.Subscriptions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].ID != id || subscriptions[0].Enabled || subscriptions[0].Time != "08:00" {
+		t.Errorf("got %+v", subscriptions)
+	}
+
+	if err := s.RemoveSubscription(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveSubscription(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("got %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestPredictions(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+	target := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	// Observations of Moscow requested by name, the nearest one to target is matched
+	for _, o := range []storage.Observation{
+		{City: "москва", Lat: 55.7504, Lon: 37.6175, ObservedAt: target.Add(10 * time.Minute), Temp: 8, Speed: 3},
+		{City: "москва", Lat: 55.7504, Lon: 37.6175, ObservedAt: target.Add(-time.Hour), Temp: 20, Speed: 9},
+		{City: "москва", Lat: 55.7504, Lon: 37.6175, ObservedAt: target, Period: time.Hour, Temp: 30, Speed: 9},
+	} {
+		if err := s.SaveObservation(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	predictions := []storage.Prediction{
+		// The same place under other name
+		{Provider: "openweather", City: "moscow", Lat: 55.7512, Lon: 37.6169, IssuedAt: target.Add(-2 * time.Hour),
+			TargetAt: target, LeadHours: 3, Temp: 10, Speed: 5},
+		// Other place with the same name
+		{Provider: "openweather", City: "москва", Lat: 46.73, Lon: -117.0, IssuedAt: target.Add(-2 * time.Hour),
+			TargetAt: target, LeadHours: 3, Temp: 10, Speed: 5},
+		// Target isn't due yet
+		{Provider: "openweather", City: "москва", Lat: 55.75, Lon: 37.62, IssuedAt: target,
+			TargetAt: target.Add(3 * time.Hour), LeadHours: 3, Temp: 10, Speed: 5},
+	}
+	if err := s.SavePredictions(ctx, predictions); err != nil {
+		t.Fatal(err)
+	}
+	// Saved once
+	if err := s.SavePredictions(ctx, predictions[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := s.MatchPredictions(ctx, target.Add(time.Hour), 90*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("matches: got %+v", matches)
+	}
+	m := matches[0]
+	if m.City != "moscow" || m.Lat != 55.75 || m.Lon != 37.62 || !m.TargetAt.Equal(target) || m.LeadHours != 3 {
+		t.Errorf("prediction: got %+v", m.Prediction)
+	}
+	if m.ObservedTemp != 8 || m.ObservedSpeed != 3 {
+		t.Errorf("observed: got %v and %v, want the nearest raw observation", m.ObservedTemp, m.ObservedSpeed)
+	}
+
+	if err := s.ResolvePredictions(ctx, matches); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := s.MatchPredictions(ctx, target.Add(time.Hour), 90*time.Minute); err != nil || len(matches) != 0 {
+		t.Errorf("resolved prediction must be deleted: %+v, %v", matches, err)
+	}
+
+	stats, err := s.ForecastStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.ForecastStats{
+		Provider: "openweather", LeadHours: 3, Count: 1, TempMAE: 2, TempRMSE: 2, TempBias: 2, SpeedMAE: 2,
+	}
+	if len(stats) != 1 || stats[0] != want {
+		t.Errorf("stats: got %+v, want %+v", stats, want)
+	}
+
+	deleted, err := s.DeletePredictions(ctx, target.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted: got %d, want the prediction of other place", deleted)
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/m1al04949/weatherbot/internal/models"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type User struct {
+	ID        int64
+	UserName  string
+	FirstName string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Chat settings, defaults are used for chats without saved settings
+type ChatSettings struct {
+	ChatID   int64
+	Language string
+	Units    string
+	Timezone string
+	City     string // default location
+	Lat      float64
+	Lon      float64
+}
+
+type Subscription struct {
+	ID        int64
+	ChatID    int64
+	City      string
+	Lat       float64
+	Lon       float64
+	Time      string // local time of delivery, HH:MM
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// Message waiting for delivery in outbound queue
+type OutboxMessage struct {
+	ID            int64
+	ChatID        int64
+	Text          string
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+// Recorded weather of the place, Period is zero for raw snapshots
+// and bucket length for downsampled ones
+type Observation struct {
+	City        string // lower case name it was requested with, for logs
+	Lat         float64
+	Lon         float64
+	ObservedAt  time.Time // in the location's timezone
+	Period      time.Duration
+	Temp        float64 // mean of period
+	MinTemp     float64
+	MaxTemp     float64
+	Humidity    float64
+	Speed       float64
+	Code        int // raw snapshots only
+	Description string
+}
+
+// Forecast value saved to compare with observed weather later,
+// LeadHours is the bucket of time between issue and target
+type Prediction struct {
+	ID        int64
+	Provider  string
+	City      string // lower case
+	Lat       float64
+	Lon       float64
+	IssuedAt  time.Time
+	TargetAt  time.Time
+	LeadHours int
+	Temp      float64
+	Speed     float64
+}
+
+// Prediction with the nearest raw observation
+type PredictionMatch struct {
+	Prediction
+	ObservedTemp  float64
+	ObservedSpeed float64
+}
+
+// Forecast error by provider and lead time
+type ForecastStats struct {
+	Provider  string
+	LeadHours int
+	Count     int
+	TempMAE   float64
+	TempRMSE  float64
+	TempBias  float64 // positive if forecasts are too warm
+	SpeedMAE  float64
+}
+
+// Action of bot operator
+type AuditEntry struct {
+	ID        int64
+	AdminID   int64
+	Action    string
+	Args      string
+	CreatedAt time.Time
+}
+
+type Repository interface {
+	// Users
+	SaveUser(ctx context.Context, user User) error
+	User(ctx context.Context, id int64) (*User, error)
+	CountUsers(ctx context.Context) (int, error)
+	UserIDs(ctx context.Context) ([]int64, error)
+
+	// Chat settings
+	SaveChatSettings(ctx context.Context, settings ChatSettings) error
+	ChatSettings(ctx context.Context, chatID int64) (*ChatSettings, error)
+
+	// Favourites
+	AddFavourite(ctx context.Context, userID int64, city string) error
+	RemoveFavourite(ctx context.Context, userID int64, city string) (bool, error)
+	Favourites(ctx context.Context, userID int64) ([]string, error)
+
+	// Subscriptions
+	AddSubscription(ctx context.Context, subscription Subscription) (int64, error)
+	RemoveSubscription(ctx context.Context, id int64) error
+	Subscriptions(ctx context.Context, chatID int64) ([]Subscription, error)
+	DisableSubscriptions(ctx context.Context, chatID int64) error
+
+	// Outbound queue
+	EnqueueMessage(ctx context.Context, message OutboxMessage) (int64, error)
+	DueMessages(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	RescheduleMessage(ctx context.Context, id int64, attempts int, next time.Time) error
+	DeleteMessage(ctx context.Context, id int64) error
+	DeleteChatMessages(ctx context.Context, chatID int64) error
+
+	// Observed weather, lat and lon are rounded to 2 digits, date is YYYY-MM-DD in the location
+	HistoryDay(ctx context.Context, lat, lon float64, date time.Time) (*models.DailyForecast, error)
+	SaveHistoryDay(ctx context.Context, lat, lon float64, day models.DailyForecast) error
+
+	// Observations time series
+	SaveObservation(ctx context.Context, observation Observation) error
+	Observations(ctx context.Context, lat, lon float64, from time.Time) ([]Observation, error)
+	DownsampleObservations(ctx context.Context, from, to time.Duration, before time.Time) (int64, error)
+	DeleteObservations(ctx context.Context, period time.Duration, before time.Time) (int64, error)
+
+	// Forecast accuracy
+	SavePredictions(ctx context.Context, predictions []Prediction) error
+	MatchPredictions(ctx context.Context, before time.Time, window time.Duration) ([]PredictionMatch, error)
+	ResolvePredictions(ctx context.Context, matches []PredictionMatch) error
+	DeletePredictions(ctx context.Context, before time.Time) (int64, error)
+	ForecastStats(ctx context.Context) ([]ForecastStats, error)
+
+	// Audit log
+	AddAudit(ctx context.Context, entry AuditEntry) error
+
+	Close() error
+}
+
+func DefaultChatSettings(chatID int64) ChatSettings {
+	return ChatSettings{
+		ChatID:   chatID,
+		Language: "ru",
+		Units:    "metric",
+		Timezone: "Europe/Moscow",
+	}
+}